@@ -2,6 +2,9 @@ module github.com/gabriel-aranha/qk
 
 go 1.22.0
 
-require go.uber.org/zap v1.26.0
+require (
+	github.com/klauspost/compress v1.18.0
+	go.uber.org/zap v1.26.0
+)
 
 require go.uber.org/multierr v1.10.0 // indirect
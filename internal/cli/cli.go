@@ -0,0 +1,497 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gabriel-aranha/qk/internal/parser"
+	"github.com/gabriel-aranha/qk/internal/reader"
+	"github.com/gabriel-aranha/qk/internal/server"
+	"github.com/gabriel-aranha/qk/internal/types"
+	"github.com/gabriel-aranha/qk/internal/writer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultInput  = "./input/games.log"
+	defaultOutput = "output/report.json"
+	stdoutOutput  = "-"
+	defaultAddr   = "localhost:8080"
+
+	defaultMaxSize    = 32 * 1024 * 1024
+	defaultMaxLogSize = 256 * 1024 * 1024
+	shutdownTimeout   = 10 * time.Second
+
+	formatJSON       = "json"
+	formatCSVGames   = "csv-games"
+	formatCSVPlayers = "csv-players"
+	formatNDJSON     = "ndjson"
+	formatMarkdown   = "markdown"
+)
+
+const usage = "Usage: qk <command> [flags] [input files]\n" +
+	"\n" +
+	"Commands:\n" +
+	"  report    parse the input logs and write the games report (default)\n" +
+	"  parse     parse the input logs and write the parsed events\n" +
+	"  follow    tail a live log and write each game as soon as it ends\n" +
+	"  serve     serve the games report over HTTP\n" +
+	"  help      show this help\n" +
+	"\n" +
+	"Run \"qk <command> -h\" to list the flags of a command.\n"
+
+type CLI struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type options struct {
+	inputs     []string
+	output     string
+	targets    []outputTarget
+	templates  []outputTarget
+	format     string
+	logLevel   string
+	fromStart  bool
+	workers    int
+	lenient    bool
+	addr       string
+	maxSize    int64
+	maxLogSize int64
+}
+
+// outputTarget is a report output or template given as [format=]path.
+type outputTarget struct {
+	format string
+	path   string
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func NewCLI(stdout, stderr io.Writer) CLI {
+	var cli CLI
+	cli.stdout = stdout
+	cli.stderr = stderr
+
+	return cli
+}
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	err := c.run(ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func (c *CLI) run(ctx context.Context, args []string) error {
+	command := "report"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "report":
+		opts, err := c.parseFlags(command, defaultOutput, args)
+		if err != nil {
+			return err
+		}
+		return c.report(opts)
+	case "parse":
+		opts, err := c.parseFlags(command, stdoutOutput, args)
+		if err != nil {
+			return err
+		}
+		return c.parse(opts)
+	case "follow":
+		opts, err := c.parseFlags(command, stdoutOutput, args)
+		if err != nil {
+			return err
+		}
+		return c.follow(ctx, opts)
+	case "serve":
+		opts, err := c.parseFlags(command, "", args)
+		if err != nil {
+			return err
+		}
+		return c.serve(ctx, opts)
+	case "help":
+		fmt.Fprint(c.stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(c.stderr, usage)
+		return fmt.Errorf("unknown command: %s", command)
+	}
+}
+
+func (c *CLI) parseFlags(command, output string, args []string) (options, error) {
+	var opts options
+	var inputs, outputs, templates stringList
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Var(&inputs, "input", "input log file or glob pattern, may be repeated (default "+defaultInput+")")
+	formats := []string{formatJSON, formatNDJSON}
+	switch command {
+	case "report":
+		formats = writer.Formats()
+		flags.Var(&outputs, "output", `output file as [format=]path, or "-" for stdout, may be repeated (default `+output+`)`)
+	case "serve":
+		// The server only answers in JSON
+		formats = []string{formatJSON}
+		flags.StringVar(&opts.addr, "addr", defaultAddr, "address to listen on")
+		flags.Int64Var(&opts.maxSize, "max-size", defaultMaxSize, "largest log upload accepted, in bytes")
+		flags.Int64Var(&opts.maxLogSize, "max-log-size", defaultMaxLogSize, "largest log upload accepted once decompressed, in bytes")
+	default:
+		flags.StringVar(&opts.output, "output", output, `output file, or "-" for stdout`)
+	}
+	opts.format = formatJSON
+	if command != "serve" {
+		flags.StringVar(&opts.format, "format", formatJSON, "output format: "+strings.Join(formats, ", "))
+	}
+	flags.StringVar(&opts.logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	flags.BoolVar(&opts.lenient, "lenient", false, "skip malformed lines instead of failing, and list them in the report")
+	if command == "report" || command == "serve" {
+		flags.IntVar(&opts.workers, "workers", 1, "number of games parsed in parallel, 0 for one per CPU")
+	}
+	if command == "report" {
+		flags.Var(&templates, "template", "template file for the markdown or html format as [format=]path, may be repeated")
+	}
+	if command == "follow" {
+		flags.BoolVar(&opts.fromStart, "from-start", false, "also report the games already in the log")
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+
+	// The server starts without a report unless it is given logs
+	opts.inputs = append(inputs, flags.Args()...)
+	if len(opts.inputs) == 0 && command != "serve" {
+		opts.inputs = []string{defaultInput}
+	}
+
+	if !slices.Contains(formats, opts.format) {
+		return opts, fmt.Errorf("unknown output format: %s", opts.format)
+	}
+
+	if command == "report" {
+		opts.targets = c.parseTargets(outputs, opts.format, formats)
+		if len(templates) > 0 {
+			opts.templates = c.parseTargets(templates, opts.format, formats)
+		}
+	}
+
+	return opts, nil
+}
+
+// parseTargets reads the report outputs. Outputs are only split on "=" when
+// what comes before it is a known format, so that other paths may contain "=".
+// Outputs without a format use the format flag, and with no outputs the report
+// goes to the default file of that format.
+func (c *CLI) parseTargets(outputs []string, format string, formats []string) []outputTarget {
+	if len(outputs) == 0 {
+		return []outputTarget{{format: format, path: c.defaultOutput(format)}}
+	}
+
+	targets := []outputTarget{}
+	for _, output := range outputs {
+		target := outputTarget{format: format, path: output}
+		name, path, ok := strings.Cut(output, "=")
+		if ok && slices.Contains(formats, name) {
+			target = outputTarget{format: name, path: path}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func (c *CLI) defaultOutput(format string) string {
+	switch format {
+	case formatJSON:
+		return defaultOutput
+	case formatCSVGames:
+		return filepath.Join(filepath.Dir(defaultOutput), "games.csv")
+	case formatCSVPlayers:
+		return filepath.Join(filepath.Dir(defaultOutput), "players.csv")
+	case formatMarkdown:
+		return filepath.Join(filepath.Dir(defaultOutput), "report.md")
+	default:
+		return filepath.Join(filepath.Dir(defaultOutput), "report."+format)
+	}
+}
+
+func (c *CLI) newLogger(logLevel string) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(level)
+
+	return config.Build()
+}
+
+func (c *CLI) report(opts options) error {
+	logger, err := c.newLogger(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	games, err := c.parseGames(logger, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.write(logger, opts, games)
+}
+
+func (c *CLI) parseGames(logger *zap.Logger, opts options) (types.Games, error) {
+	reader := reader.NewReader(logger)
+	inputs, err := reader.Expand(opts.inputs...)
+	if err != nil {
+		return types.Games{}, err
+	}
+	lines := reader.Open(inputs...)
+	defer lines.Close()
+
+	parser := parser.NewParser(logger)
+	parser.SetLenient(opts.lenient)
+	games := types.Games{Games: make(map[string]types.Game)}
+	err = parser.ParseConcurrent(lines, opts.workers, func(key string, game types.Game) error {
+		games.Games[key] = game
+		return nil
+	})
+	if err != nil {
+		logger.Error("error parsing file", zap.Error(err))
+		return types.Games{}, err
+	}
+
+	games.Rankings = parser.Rankings(games.Games)
+
+	diagnostics := games.Diagnostics()
+	if len(diagnostics) > 0 {
+		logger.Warn("skipped malformed lines", zap.Int("count", len(diagnostics)))
+	}
+
+	return games, nil
+}
+
+func (c *CLI) parse(opts options) error {
+	logger, err := c.newLogger(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	reader := reader.NewReader(logger)
+	inputs, err := reader.Expand(opts.inputs...)
+	if err != nil {
+		return err
+	}
+	lines := reader.Open(inputs...)
+	defer lines.Close()
+
+	writer := writer.NewWriter(logger)
+	out := c.stdout
+	if opts.output != stdoutOutput {
+		file, err := writer.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	parser := parser.NewParser(logger)
+	parser.SetLenient(opts.lenient)
+
+	// NDJSON events are written as they are parsed, without holding them in memory
+	if opts.format == formatNDJSON {
+		buffered := bufio.NewWriter(out)
+		err = parser.EmitStream(lines, func(event types.Event) error {
+			return writer.EncodeEvent(buffered, event)
+		})
+		if err == nil {
+			err = buffered.Flush()
+		}
+		if err != nil {
+			logger.Error("error parsing file", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	events := []types.Event{}
+	err = parser.EmitStream(lines, func(event types.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		logger.Error("error parsing file", zap.Error(err))
+		return err
+	}
+
+	return writer.Encode(out, events)
+}
+
+func (c *CLI) follow(ctx context.Context, opts options) error {
+	if len(opts.inputs) != 1 {
+		return fmt.Errorf("follow takes a single input file, got %d", len(opts.inputs))
+	}
+
+	logger, err := c.newLogger(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	writer := writer.NewWriter(logger)
+	out := c.stdout
+	if opts.output != stdoutOutput {
+		file, err := writer.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	reader := reader.NewReader(logger)
+	lines := reader.Follow(ctx, opts.inputs[0])
+	defer lines.Close()
+
+	// Every game is written as its own report as soon as it ends. The log is read
+	// from the start so that games are numbered as in the report, but the games
+	// that ended before following started are skipped unless asked for
+	parser := parser.NewParser(logger)
+	parser.SetLenient(opts.lenient)
+	parser.SetLive(true)
+	err = parser.ParseStream(lines, func(key string, game types.Game) error {
+		if lines.Backlog() && !opts.fromStart {
+			return nil
+		}
+
+		games := types.Games{Games: map[string]types.Game{key: game}}
+		if opts.format == formatNDJSON {
+			return writer.EncodeLine(out, games)
+		}
+
+		err := writer.Encode(out, games)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out)
+		return err
+	})
+	if err != nil {
+		logger.Error("error following file", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (c *CLI) serve(ctx context.Context, opts options) error {
+	logger, err := c.newLogger(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	defer logger.Sync()
+
+	server := server.NewServer(logger)
+	server.SetMaxBodySize(opts.maxSize)
+	server.SetMaxLogSize(opts.maxLogSize)
+	server.SetWorkers(opts.workers)
+	server.SetLenient(opts.lenient)
+
+	if len(opts.inputs) > 0 {
+		games, err := c.parseGames(logger, opts)
+		if err != nil {
+			return err
+		}
+		server.Load(games)
+	}
+
+	httpServer := &http.Server{
+		Addr:              opts.addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+	logger.Info("serving games report", zap.String("addr", opts.addr))
+
+	select {
+	case err := <-errs:
+		logger.Error("error serving", zap.Error(err))
+		return err
+	case <-ctx.Done():
+	}
+
+	// Requests in flight are given some time to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Error("error shutting down server", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (c *CLI) write(logger *zap.Logger, opts options, games types.Games) error {
+	w := writer.NewWriter(logger)
+
+	for _, template := range opts.templates {
+		err := w.LoadTemplate(template.format, template.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	outputs := make([]writer.Output, len(opts.targets))
+	for i, target := range opts.targets {
+		var err error
+		if target.path == stdoutOutput {
+			outputs[i], err = w.NewStreamOutput(target.format, c.stdout)
+		} else {
+			outputs[i], err = w.NewFileOutput(target.format, target.path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// The report is parsed once and written to every output
+	err := writer.Fanout(outputs...).WriteGames(games)
+	if err != nil {
+		logger.Error("error writing file", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,620 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+)
+
+var testLog = strings.Join([]string{
+	"  0:00 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm17",
+	" 20:34 ClientConnect: 2",
+	" 20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0\\model\\xian/default",
+	" 20:35 ClientConnect: 3",
+	" 20:35 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0\\model\\sarge",
+	" 20:54 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+	" 21:07 ShutdownGame:",
+}, "\n")
+
+func writeTestLog(t *testing.T) string {
+	filePath := filepath.Join(t.TempDir(), "games.log")
+	err := os.WriteFile(filePath, []byte(testLog), 0644)
+	if err != nil {
+		t.Fatalf("Error writing test log: %v", err)
+	}
+	return filePath
+}
+
+func TestRunReport(t *testing.T) {
+	input := writeTestLog(t)
+	output := filepath.Join(t.TempDir(), "reports", "report.json")
+
+	tests := []struct {
+		description string
+		args        []string
+		outputFile  string
+	}{
+		{
+			description: "report to file",
+			args:        []string{"report", "-input", input, "-output", output, "-log-level", "error"},
+			outputFile:  output,
+		},
+		{
+			description: "report to stdout",
+			args:        []string{"report", "-output", "-", "-log-level", "error", input},
+		},
+		{
+			description: "report with several workers",
+			args:        []string{"report", "-output", "-", "-log-level", "error", "-workers", "4", input},
+		},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		c := NewCLI(&stdout, &stderr)
+
+		err := c.Run(context.Background(), test.args)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+
+		content := stdout.Bytes()
+		if test.outputFile != "" {
+			content, err = os.ReadFile(test.outputFile)
+			if err != nil {
+				t.Errorf("%s: Error reading output file: %v", test.description, err)
+				continue
+			}
+		}
+
+		var games types.Games
+		err = json.Unmarshal(content, &games)
+		if err != nil {
+			t.Errorf("%s: Error decoding report: %v", test.description, err)
+			continue
+		}
+		if games.Games["game_1"].Kills["Isgalamido"] != 1 {
+			t.Errorf("%s: Expected 1 kill for Isgalamido, got %v", test.description, games.Games["game_1"].Kills)
+		}
+	}
+}
+
+func TestRunReportArchives(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Archives are read by name, whatever their modification time
+	archives := []struct {
+		name    string
+		mapName string
+		modTime time.Time
+	}{
+		{name: "a.log.gz", mapName: "q3dm17", modTime: start.Add(24 * time.Hour)},
+		{name: "b.log.gz", mapName: "q3dm6", modTime: start},
+	}
+	for _, archive := range archives {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		writer.Write([]byte(strings.Replace(testLog, "q3dm17", archive.mapName, 1)))
+		writer.Close()
+
+		filePath := filepath.Join(dir, archive.name)
+		err := os.WriteFile(filePath, buffer.Bytes(), 0644)
+		if err == nil {
+			err = os.Chtimes(filePath, archive.modTime, archive.modTime)
+		}
+		if err != nil {
+			t.Fatalf("Error writing test archive: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err := c.Run(context.Background(), []string{"report", "-output", "-", "-log-level", "error", filepath.Join(dir, "*.gz")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var games types.Games
+	err = json.Unmarshal(stdout.Bytes(), &games)
+	if err != nil {
+		t.Fatalf("Error decoding report: %v", err)
+	}
+
+	expectedMaps := map[string]string{"game_1": "q3dm17", "game_2": "q3dm6"}
+	for key, mapName := range expectedMaps {
+		game, ok := games.Games[key]
+		if !ok || game.Metadata == nil || game.Metadata.Map != mapName {
+			t.Errorf("Expected %s to be played on %s, got %+v", key, mapName, game.Metadata)
+		}
+	}
+	if len(games.Games) != len(expectedMaps) {
+		t.Errorf("Expected %d games, got %d", len(expectedMaps), len(games.Games))
+	}
+}
+
+func TestRunReportCSV(t *testing.T) {
+	input := writeTestLog(t)
+
+	tests := []struct {
+		description string
+		format      string
+		expected    []string
+	}{
+		{
+			description: "games table",
+			format:      "csv-games",
+			expected: []string{
+				"game,map,game_type,total_kills,players,duration,exit_reason,incomplete",
+				"game_1,q3dm17,FFA,1,2,1267,,false",
+			},
+		},
+		{
+			description: "players table",
+			format:      "csv-players",
+			expected: []string{
+				"game,player,kills,frags,deaths,suicides,world_deaths,score,kd_ratio,team,kills_MOD_RAILGUN,deaths_MOD_RAILGUN",
+				"game_1,Isgalamido,1,1,0,0,0,1,1,free,1,0",
+				"game_1,Dono da Bola,0,0,1,0,0,0,0,free,0,1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		c := NewCLI(&stdout, &stderr)
+
+		err := c.Run(context.Background(), []string{"report", "-format", test.format, "-output", "-", "-log-level", "error", input})
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+
+		expected := strings.Join(test.expected, "\n") + "\n"
+		if stdout.String() != expected {
+			t.Errorf("%s: Expected %q, got %q", test.description, expected, stdout.String())
+		}
+	}
+}
+
+func TestRunReportOutputs(t *testing.T) {
+	input := writeTestLog(t)
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "report.json")
+	csvFile := filepath.Join(dir, "tables", "games.csv")
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err := c.Run(context.Background(), []string{"report", "-log-level", "error", "-output", jsonFile, "-output", "csv-games=" + csvFile, "-output", "csv-players=-", input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("Error reading JSON report: %v", err)
+	}
+	var games types.Games
+	err = json.Unmarshal(content, &games)
+	if err != nil || len(games.Games) != 1 {
+		t.Errorf("Expected a JSON report with one game, got %v (error: %v)", games.Games, err)
+	}
+
+	content, err = os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatalf("Error reading CSV table: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "game,map,") {
+		t.Errorf("Expected the games table, got %q", content)
+	}
+	if !strings.HasPrefix(stdout.String(), "game,player,") {
+		t.Errorf("Expected the players table on stdout, got %q", stdout.String())
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	c := NewCLI(io.Discard, io.Discard)
+	formats := []string{formatJSON, formatCSVGames}
+
+	tests := []struct {
+		description     string
+		outputs         []string
+		expectedTargets []outputTarget
+	}{
+		{
+			description:     "no outputs",
+			outputs:         nil,
+			expectedTargets: []outputTarget{{format: formatJSON, path: defaultOutput}},
+		},
+		{
+			description:     "output with a format",
+			outputs:         []string{"csv-games=games.csv"},
+			expectedTargets: []outputTarget{{format: formatCSVGames, path: "games.csv"}},
+		},
+		{
+			description:     "path containing an equals sign",
+			outputs:         []string{"run=1.json", "xml=report.xml"},
+			expectedTargets: []outputTarget{{format: formatJSON, path: "run=1.json"}, {format: formatJSON, path: "xml=report.xml"}},
+		},
+	}
+
+	for _, test := range tests {
+		targets := c.parseTargets(test.outputs, formatJSON, formats)
+		if !reflect.DeepEqual(targets, test.expectedTargets) {
+			t.Errorf("%s: Expected %+v, got %+v", test.description, test.expectedTargets, targets)
+		}
+	}
+}
+
+func TestRunReportTemplate(t *testing.T) {
+	input := writeTestLog(t)
+	dir := t.TempDir()
+
+	templatePath := filepath.Join(dir, "wiki.tmpl")
+	err := os.WriteFile(templatePath, []byte(`{{range .Rankings}}{{.Player}} {{.Kills}}|{{end}}`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing template file: %v", err)
+	}
+	htmlPath := filepath.Join(dir, "report.html")
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err = c.Run(context.Background(), []string{"report", "-output", "markdown=-", "-output", "html=" + htmlPath, "-template", "markdown=" + templatePath, "-log-level", "error", input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "Isgalamido 1|Dono da Bola 0|"
+	if stdout.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout.String())
+	}
+
+	// The html output keeps the default template
+	content, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatalf("Error reading html report: %v", err)
+	}
+	if !strings.Contains(string(content), "<h2>Leaderboard</h2>") {
+		t.Errorf("Expected the default html report, got %q", content)
+	}
+}
+
+func TestRunParse(t *testing.T) {
+	input := writeTestLog(t)
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err := c.Run(context.Background(), []string{"parse", "-log-level", "error", input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var events []types.Event
+	err = json.Unmarshal(stdout.Bytes(), &events)
+	if err != nil {
+		t.Fatalf("Error decoding events: %v", err)
+	}
+	if len(events) != 7 {
+		t.Errorf("Expected 7 events, got %v", len(events))
+	}
+}
+
+func TestRunParseNDJSON(t *testing.T) {
+	input := writeTestLog(t)
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err := c.Run(context.Background(), []string{"parse", "-format", "ndjson", "-log-level", "error", input})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("Expected 7 lines, got %v", len(lines))
+	}
+	for i, line := range lines {
+		var record struct {
+			GameKey string `json:"game_key"`
+			Line    int    `json:"line"`
+		}
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Errorf("Error decoding line %d: %v", i+1, err)
+			continue
+		}
+		if record.GameKey != "game_1" || record.Line != i+1 {
+			t.Errorf("Expected game_1 line %d, got %+v", i+1, record)
+		}
+	}
+}
+
+func TestRunLenient(t *testing.T) {
+	// The malformed line is line 6 of the second file, and line 8 of the
+	// files read together
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a.log")
+	input := filepath.Join(dir, "b.log")
+	files := map[string]string{
+		first: "  0:00 InitGame: \\mapname\\q3dm6\n  1:00 ShutdownGame:\n",
+		input: strings.Replace(testLog, "Dono da Bola by MOD_RAILGUN", "Dono da", 1),
+	}
+	for filePath, content := range files {
+		err := os.WriteFile(filePath, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Error writing test log: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+
+	err := c.Run(context.Background(), []string{"report", "-output", "-", "-log-level", "fatal", first, input})
+	expectedErr := input + ":6 (game_2)"
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("Expected error at %s in strict mode, got %v", expectedErr, err)
+	}
+
+	stdout.Reset()
+	err = c.Run(context.Background(), []string{"report", "-output", "-", "-log-level", "fatal", "-lenient", first, input})
+	if err != nil {
+		t.Fatalf("Unexpected error in lenient mode: %v", err)
+	}
+
+	var games types.Games
+	err = json.Unmarshal(stdout.Bytes(), &games)
+	if err != nil {
+		t.Fatalf("Error decoding report: %v", err)
+	}
+	diagnostics := games.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].File != input || diagnostics[0].Line != 6 {
+		t.Errorf("Expected one diagnostic for line 6 of %s, got %+v", input, diagnostics)
+	}
+
+	// The parsed events list the skipped line in its place
+	stdout.Reset()
+	err = c.Run(context.Background(), []string{"parse", "-format", "ndjson", "-log-level", "fatal", "-lenient", input})
+	if err != nil {
+		t.Fatalf("Unexpected error in lenient mode: %v", err)
+	}
+	expected := `{"game_key":"game_1","kind":"diagnostic","game":1,"file":` + strconv.Quote(input) + `,"line":6,"time":1254,"diagnostic":{"file":` + strconv.Quote(input) + `,"line":6,"game":"game_1","raw":" 20:54 Kill: 2 3 10: Isgalamido killed Dono da","reason":"could not parse means: 2 3 10: Isgalamido killed Dono da"}}`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Errorf("Expected %s in the events, got %s", expected, stdout.String())
+	}
+}
+
+func TestRunServe(t *testing.T) {
+	input := writeTestLog(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error finding a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Run(ctx, []string{"serve", "-addr", addr, "-log-level", "error", input})
+	}()
+
+	// The report of the given logs is served as soon as the server is up
+	var body []byte
+	for i := 0; i < 50; i++ {
+		response, err := http.Get("http://" + addr + "/games/game_1")
+		if err == nil {
+			body, err = io.ReadAll(response.Body)
+			response.Body.Close()
+			if err != nil {
+				t.Fatalf("Error reading response: %v", err)
+			}
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var game types.Game
+	err = json.Unmarshal(body, &game)
+	if err != nil {
+		t.Fatalf("Error decoding response %q: %v", body, err)
+	}
+	if game.TotalKills != 1 {
+		t.Errorf("Expected 1 kill in game_1, got %d", game.TotalKills)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the server to stop once cancelled")
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be read while a command writes to it.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+func TestRunFollow(t *testing.T) {
+	input := writeTestLog(t)
+
+	// The log ends in the middle of a game when following starts
+	file, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Error opening test log: %v", err)
+	}
+	defer file.Close()
+	_, err = file.WriteString(strings.Join([]string{
+		"",
+		"  0:00 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm6",
+		" 20:34 ClientConnect: 2",
+		" 20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0\\model\\xian/default",
+		" 20:35 ClientConnect: 3",
+		" 20:35 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0\\model\\sarge",
+		" 20:40 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+		"",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("Error writing test log: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout syncBuffer
+	var stderr bytes.Buffer
+	c := NewCLI(&stdout, &stderr)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Run(ctx, []string{"follow", "-format", "ndjson", "-log-level", "error", input})
+	}()
+
+	// The rest of the game, and the start of the next one, are written while following
+	time.Sleep(100 * time.Millisecond)
+	_, err = file.WriteString(strings.Join([]string{
+		" 20:50 Kill: 3 2 10: Dono da Bola killed Isgalamido by MOD_RAILGUN",
+		" 21:00 ShutdownGame:",
+		" 21:05 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm17",
+		"",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("Error writing test log: %v", err)
+	}
+
+	for i := 0; i < 100 && !strings.Contains(stdout.String(), "\n"); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	err = <-errs
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Only the game that ended while following is written, whole and under its report key
+	var games types.Games
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single game, got %q", stdout.String())
+	}
+	err = json.Unmarshal([]byte(lines[0]), &games)
+	if err != nil {
+		t.Fatalf("Error decoding game: %v", err)
+	}
+	game, ok := games.Games["game_2"]
+	if !ok {
+		t.Fatalf("Expected game_2, got %v", games.Games.Keys())
+	}
+	if game.TotalKills != 2 || game.Metadata == nil || game.Metadata.Map != "q3dm6" {
+		t.Errorf("Expected the whole game, got %+v", game)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	input := writeTestLog(t)
+
+	tests := []struct {
+		description string
+		args        []string
+		expectError bool
+	}{
+		{
+			description: "unknown command",
+			args:        []string{"export"},
+			expectError: true,
+		},
+		{
+			description: "serve with a missing log",
+			args:        []string{"serve", "-addr", "127.0.0.1:0", "-log-level", "fatal", filepath.Join(t.TempDir(), "missing.log")},
+			expectError: true,
+		},
+		{
+			description: "unknown format",
+			args:        []string{"report", "-format", "xml", input},
+			expectError: true,
+		},
+		{
+			description: "csv events",
+			args:        []string{"parse", "-format", "csv-games", input},
+			expectError: true,
+		},
+		{
+			description: "template for a format without templates",
+			args:        []string{"report", "-output", "-", "-template", "json=" + input, "-log-level", "error", input},
+			expectError: true,
+		},
+		{
+			description: "unknown log level",
+			args:        []string{"report", "-log-level", "loud", "-output", "-", input},
+			expectError: true,
+		},
+		{
+			description: "missing input file",
+			args:        []string{"report", "-log-level", "fatal", "-output", "-", filepath.Join(t.TempDir(), "missing.log")},
+			expectError: true,
+		},
+		{
+			description: "follow several files",
+			args:        []string{"follow", "-log-level", "fatal", input, input},
+			expectError: true,
+		},
+		{
+			description: "help",
+			args:        []string{"report", "-h"},
+			expectError: false,
+		},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		c := NewCLI(&stdout, &stderr)
+
+		err := c.Run(context.Background(), test.args)
+		if test.expectError && err == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+	}
+}
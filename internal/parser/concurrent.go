@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+var errStopped = errors.New("parsing stopped")
+
+type gameResult struct {
+	index int
+	key   string
+	game  types.Game
+	err   error
+}
+
+// ParseConcurrent is like ParseStream, but parses the games on a pool of
+// workers, one game per worker at a time. Games are still handed to handle in
+// log order. A workers count below 1 uses one worker per CPU.
+func (p *Parser) ParseConcurrent(scanner LineScanner, workers int, handle GameHandler) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 {
+		return p.ParseStream(scanner, handle)
+	}
+
+	chunks := make(chan gameChunk)
+	results := make(chan gameResult)
+	done := make(chan struct{})
+	// slots bounds how many games are read ahead of the one being handed over
+	slots := make(chan struct{}, 2*workers)
+
+	var splitErr error
+	splitDone := make(chan struct{})
+	go func() {
+		defer close(splitDone)
+		defer close(chunks)
+		splitErr = p.splitGames(scanner, func(chunk gameChunk) error {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return errStopped
+			}
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-done:
+				return errStopped
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				game, err := p.parseGame(chunk)
+				result := gameResult{index: chunk.index, key: p.formatGameNumber(chunk.gameNumber), game: game, err: err}
+				select {
+				case results <- result:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	err := p.handleInOrder(results, slots, handle)
+	if err != nil {
+		// Stop the splitter and the workers, and wait for them before the
+		// scanner is handed back
+		close(done)
+		for range results {
+		}
+		<-splitDone
+		p.logger.Error("error processing new game", zap.Error(err))
+		return err
+	}
+
+	<-splitDone
+	if splitErr != nil {
+		p.logger.Error("error processing new game", zap.Error(splitErr))
+		return splitErr
+	}
+	return nil
+}
+
+// handleInOrder hands the results to handle by game order, holding back those
+// that finish before the games preceding them.
+func (p *Parser) handleInOrder(results <-chan gameResult, slots <-chan struct{}, handle GameHandler) error {
+	pending := make(map[int]gameResult)
+	next := 0
+	for result := range results {
+		pending[result.index] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+
+			if result.err != nil {
+				return result.err
+			}
+			err := handle(result.key, result.game)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+)
+
+// ParseError reports a log line that could not be parsed. Use errors.As to get
+// it from the errors returned by the parser. Line counts from the start of File,
+// which is empty unless the lines came from a FileScanner.
+type ParseError struct {
+	File string
+	Line int
+	Game string
+	Kind types.EventKind
+	Raw  string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d (%s): %s: %v", e.File, e.Line, e.Game, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("line %d (%s): %s: %v", e.Line, e.Game, e.Kind, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+// EventHandler receives every event emitted by the parser, in log order.
+type EventHandler func(event types.Event) error
+
+// Events parses all lines and returns the resulting events in log order.
+func (p *Parser) Events(arrayLines []string) ([]types.Event, error) {
+	events := []types.Event{}
+	err := p.Emit(arrayLines, func(event types.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// Emit parses all lines and calls handle for each event, in log order. Lines
+// that carry no event, such as the separator lines, are skipped.
+func (p *Parser) Emit(arrayLines []string, handle EventHandler) error {
+	return p.EmitStream(&sliceScanner{lines: arrayLines}, handle)
+}
+
+// EmitStream is like Emit, but reads the lines one at a time from scanner.
+func (p *Parser) EmitStream(scanner LineScanner, handle EventHandler) error {
+	lineNumber := 0
+	gameNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		event, ok, err := p.readEvent(p.position(scanner, lineNumber), gameNumber, line)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if event.Kind == types.EventInitGame {
+			gameNumber++
+		}
+		event.Game = gameNumber
+
+		err = handle(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		p.logger.Error("error scanning lines", zap.Int("line", lineNumber), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// readEvent parses a line of the given game. In strict mode a malformed line
+// fails with a *ParseError, while in lenient mode it is logged and returned as
+// an EventDiagnostic event instead.
+func (p *Parser) readEvent(pos position, gameNumber int, line string) (types.Event, bool, error) {
+	event, ok, err := p.parseEvent(pos.line, line)
+	event.File = pos.file
+	if err == nil {
+		return event, ok, nil
+	}
+
+	parseErr := &ParseError{
+		File: pos.file,
+		Line: pos.line,
+		Game: p.formatGameNumber(gameNumber),
+		Kind: event.Kind,
+		Raw:  line,
+		Err:  err,
+	}
+	if !p.lenient {
+		p.logger.Error("error parsing event", zap.Error(parseErr))
+		return event, false, parseErr
+	}
+
+	p.logger.Warn("skipping malformed line", zap.Error(parseErr))
+	return types.Event{
+		Kind: types.EventDiagnostic,
+		File: pos.file,
+		Line: pos.line,
+		Time: event.Time,
+		Diagnostic: &types.Diagnostic{
+			File:   parseErr.File,
+			Line:   parseErr.Line,
+			Game:   parseErr.Game,
+			Raw:    parseErr.Raw,
+			Reason: err.Error(),
+		},
+	}, true, nil
+}
+
+func (p *Parser) parseEvent(lineNumber int, line string) (types.Event, bool, error) {
+	token, ok := tokenize(line)
+	if !ok {
+		return types.Event{}, false, nil
+	}
+
+	event := types.Event{
+		Kind: types.EventKind(token.kind),
+		Line: lineNumber,
+		Time: token.time,
+	}
+	args := token.args
+
+	switch event.Kind {
+	case types.EventInitGame:
+		event.ServerVars = parseInfoString(args)
+	case types.EventClientConnect, types.EventClientBegin, types.EventClientDisconnect:
+		event.ClientID = args
+	case types.EventClientUserinfoChanged:
+		userID, username, info, err := parseUserDetails(args)
+		if err != nil {
+			return event, false, err
+		}
+		userinfo, err := DecodeUserinfo(info)
+		if err != nil {
+			return event, false, err
+		}
+		event.ClientID = userID
+		event.Username = username
+		event.Userinfo = &userinfo
+	case types.EventKill:
+		kill, err := parseKill(args)
+		if err != nil {
+			return event, false, err
+		}
+		event.Kill = &kill
+	case types.EventItem:
+		clientID, item, _ := strings.Cut(args, " ")
+		event.ClientID = clientID
+		event.Item = strings.TrimSpace(item)
+	case types.EventSay:
+		username, message, _ := strings.Cut(args, ": ")
+		event.Username = username
+		event.Message = message
+	case types.EventScore:
+		score, err := parseScore(args)
+		if err != nil {
+			return event, false, err
+		}
+		event.ClientID = score.ClientID
+		event.Username = score.Name
+		event.Score = &score
+	case types.EventExit:
+		event.Message = args
+	case types.EventShutdownGame:
+	case "red":
+		event.Kind = types.EventTeamScore
+		teamScore, err := parseTeamScore(args)
+		if err != nil {
+			return event, false, err
+		}
+		event.TeamScore = &teamScore
+	default:
+		return event, false, nil
+	}
+
+	return event, true, nil
+}
+
+// parseInfoString decodes a Quake 3 info string of the form \key\value\key\value.
+func parseInfoString(info string) map[string]string {
+	vars := make(map[string]string)
+	parts := strings.Split(strings.TrimPrefix(info, "\\"), "\\")
+	for i := 0; i+1 < len(parts); i += 2 {
+		vars[parts[i]] = parts[i+1]
+	}
+	return vars
+}
@@ -2,7 +2,8 @@ package parser
 
 import (
 	"fmt"
-	"regexp"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/gabriel-aranha/qk/internal/types"
@@ -10,11 +11,35 @@ import (
 )
 
 const (
-	worldKiller = "<world>"
+	worldID = "1022"
+
+	teamFree      = "free"
+	teamRed       = "red"
+	teamBlue      = "blue"
+	teamSpectator = "spectator"
+	teamTie       = "tie"
 )
 
+// scoredByFrags holds the game types whose server score only counts frags,
+// suicides and world deaths. Team games also score captures, assists and
+// defending the flag, which the log does not show.
+var scoredByFrags = map[int]bool{
+	0: true,
+	1: true,
+}
+
+var gameTypeNames = map[int]string{
+	0: "FFA",
+	1: "Tournament",
+	2: "Single Player",
+	3: "TDM",
+	4: "CTF",
+}
+
 type Parser struct {
-	logger *zap.Logger
+	logger  *zap.Logger
+	lenient bool
+	live    bool
 }
 
 func NewParser(logger *zap.Logger) Parser {
@@ -24,74 +49,128 @@ func NewParser(logger *zap.Logger) Parser {
 	return parser
 }
 
+// SetLenient switches the parser between strict mode, the default, where the
+// first malformed line aborts parsing, and lenient mode, where malformed lines
+// are skipped and recorded in the Diagnostics of their game.
+func (p *Parser) SetLenient(lenient bool) {
+	p.lenient = lenient
+}
+
+// SetLive is for logs that are still being written to, such as a followed log.
+// Lines before the first InitGame are dropped instead of reported as game 0, and
+// the game still open when the lines stop is not reported, as it has not ended.
+func (p *Parser) SetLive(live bool) {
+	p.live = live
+}
+
 func (p *Parser) formatGameNumber(gameNumber int) string {
-	return fmt.Sprintf("game_%d", gameNumber)
+	return types.FormatGameKey(gameNumber)
 }
 
 func (p *Parser) Parse(arrayLines []string) (types.Games, error) {
-	var startLineIndex int
-	gameNumber := 0
 	games := types.Games{Games: make(map[string]types.Game)}
-	for i, line := range arrayLines {
-		if p.isInitGameLine(line) {
-			if i != 0 {
-				game, err := p.processNewGame(gameNumber, arrayLines[startLineIndex:i])
-				if err != nil {
-					p.logger.Error("error processing new game", zap.Error(err))
-					return games, err
-				}
-				games.Games[p.formatGameNumber(gameNumber)] = game
-			}
-			startLineIndex = i
-			gameNumber++
-		}
-	}
-	if startLineIndex != len(arrayLines) {
-		game, err := p.processNewGame(gameNumber, arrayLines[startLineIndex:])
-		if err != nil {
-			p.logger.Error("error processing new game", zap.Error(err))
-			return games, err
-		}
-		games.Games[p.formatGameNumber(gameNumber)] = game
+
+	err := p.ParseStream(&sliceScanner{lines: arrayLines}, func(key string, game types.Game) error {
+		games.Games[key] = game
+		return nil
+	})
+	if err != nil {
+		return games, err
 	}
+
+	games.Rankings = p.Rankings(games.Games)
 	return games, nil
 }
 
 func (p *Parser) newGame() types.Game {
 	return types.Game{
-		TotalKills:   0,
-		Players:      []string{},
-		PlayerList:   []types.Player{},
-		Kills:        make(map[string]int),
-		KillsByMeans: make(map[string]int),
+		TotalKills:     0,
+		Players:        []string{},
+		PlayerList:     []types.Player{},
+		Kills:          make(map[string]int),
+		KillsByMeans:   make(map[string]int),
+		KillMatrix:     make(map[string]map[string]int),
+		KillMatrixByID: make(map[string]map[string]int),
+		Incomplete:     true,
 	}
 }
 
-func (p *Parser) processNewGame(gameNumber int, gameLines []string) (types.Game, error) {
-	game := p.newGame()
+func (p *Parser) processEvent(event types.Event, game types.Game) (types.Game, error) {
+	// Skipped lines are only listed, they do not move the game on
+	if event.Kind == types.EventDiagnostic {
+		game.Diagnostics = append(game.Diagnostics, *event.Diagnostic)
+		return game, nil
+	}
 
-	for _, line := range gameLines {
-		if p.isKillLine(line) {
-			var err error
-			game, err = p.processKillLine(line, game)
-			if err != nil {
-				p.logger.Error("error processing kill line", zap.Error(err))
-				return game, err
-			}
-		} else if p.isUserInfoLine(line) {
-			var err error
-			game, err = p.processUserInfoLine(line, game)
-			if err != nil {
-				p.logger.Error("error processing client user info line", zap.Error(err))
-				return game, err
-			}
-		}
+	// Until the game ends, its end time follows the latest event
+	if game.Incomplete {
+		game.EndTime = event.Time
+	}
+
+	switch event.Kind {
+	case types.EventKill:
+		return p.applyKill(*event.Kill, game), nil
+	case types.EventClientConnect, types.EventClientDisconnect:
+		// A client id is freed on disconnect and may be reused by the next client
+		return p.applyDisconnect(event.ClientID, game), nil
+	case types.EventClientUserinfoChanged:
+		game = p.applyUserInfo(event.ClientID, event.Username, game)
+		return p.applyUserinfoDetails(event.ClientID, *event.Userinfo, game), nil
+	case types.EventInitGame:
+		game.Metadata = p.extractMetadata(event.ServerVars)
+		game.StartTime = event.Time
+	case types.EventExit:
+		game.ExitReason = strings.TrimSuffix(event.Message, ".")
+		game.Incomplete = false
+	case types.EventShutdownGame:
+		game.EndTime = event.Time
+		game.Incomplete = false
+	case types.EventScore:
+		game = p.applyScore(*event.Score, game)
+	case types.EventTeamScore:
+		game = p.applyTeamScore(*event.TeamScore, game)
+	}
+	return game, nil
+}
+
+func (p *Parser) extractMetadata(serverVars map[string]string) *types.GameMetadata {
+	gameTypeID, _ := strconv.Atoi(serverVars["g_gametype"])
+	fragLimit, _ := strconv.Atoi(serverVars["fraglimit"])
+	timeLimit, _ := strconv.Atoi(serverVars["timelimit"])
+	captureLimit, _ := strconv.Atoi(serverVars["capturelimit"])
+	protocol, _ := strconv.Atoi(serverVars["protocol"])
+
+	gameType, ok := gameTypeNames[gameTypeID]
+	if !ok {
+		gameType = fmt.Sprintf("Unknown (%d)", gameTypeID)
+	}
+
+	return &types.GameMetadata{
+		Map:          serverVars["mapname"],
+		GameType:     gameType,
+		GameTypeID:   gameTypeID,
+		FragLimit:    fragLimit,
+		TimeLimit:    timeLimit,
+		CaptureLimit: captureLimit,
+		Hostname:     serverVars["sv_hostname"],
+		Version:      serverVars["version"],
+		Protocol:     protocol,
+		ServerVars:   serverVars,
+	}
+}
+
+func (p *Parser) finishGame(game types.Game) types.Game {
+	game.Duration = int(game.EndTime - game.StartTime)
+
+	for i, player := range game.PlayerList {
+		game.PlayerList[i].Score = player.Frags - player.Suicides - player.WorldDeaths
+		game.PlayerList[i].KDRatio = p.kdRatio(player.Frags, player.Deaths)
 	}
 
-	// Add all players with kills to the Kills field
+	// Add all players with kills to the Kills field, players sharing a name add up
 	for _, player := range game.PlayerList {
 		if player.Kills > 0 {
-			game.Kills[player.CurrentUsername] = player.Kills
+			game.Kills[player.CurrentUsername] += player.Kills
 		}
 	}
 
@@ -100,135 +179,281 @@ func (p *Parser) processNewGame(gameNumber int, gameLines []string) (types.Game,
 		game.Players = append(game.Players, player.CurrentUsername)
 	}
 
-	return game, nil
+	game = p.buildKillMatrix(game)
+	game.Mismatches = p.checkScoreboard(game)
+	game = p.buildTeamRosters(game)
+
+	return game
 }
 
-func (p *Parser) processUserInfoLine(line string, game types.Game) (types.Game, error) {
-	userID, currentUsername, err := p.extractUserDetails(line)
-	if err != nil {
-		p.logger.Error("error extracting client user info line", zap.Error(err))
-		return game, err
+func (p *Parser) buildKillMatrix(game types.Game) types.Game {
+	// Kills between players are recorded by player, key them by client id and
+	// name them by their final usernames
+	for _, player := range game.PlayerList {
+		for victimIndex, count := range player.KillsByVictim {
+			victim := game.PlayerList[victimIndex]
+			countKills(game.KillMatrixByID, player.UserID, victim.UserID, count)
+			countKills(game.KillMatrix, player.CurrentUsername, victim.CurrentUsername, count)
+		}
+	}
+	return game
+}
+
+func countKills(matrix map[string]map[string]int, killer, victim string, count int) {
+	if matrix[killer] == nil {
+		matrix[killer] = make(map[string]int)
+	}
+	matrix[killer][victim] += count
+}
+
+func (p *Parser) applyUserinfoDetails(userID string, userinfo types.Userinfo, game types.Game) types.Game {
+	playerIndex := p.findPlayer(userID, game)
+	if playerIndex >= 0 {
+		game.PlayerList[playerIndex].Userinfo = userinfo
+		game.PlayerList[playerIndex].Team = userinfo.Team
+	}
+	return game
+}
+
+func (p *Parser) applyTeamScore(teamScore types.TeamScore, game types.Game) types.Game {
+	if game.Teams == nil {
+		game.Teams = &types.Teams{}
+	}
+
+	game.Teams.Red.Score = teamScore.Red
+	game.Teams.Blue.Score = teamScore.Blue
+
+	switch {
+	case teamScore.Red > teamScore.Blue:
+		game.Teams.Winner = teamRed
+	case teamScore.Blue > teamScore.Red:
+		game.Teams.Winner = teamBlue
+	default:
+		game.Teams.Winner = teamTie
+	}
+
+	return game
+}
+
+func (p *Parser) applyTeamKill(team string, game types.Game) types.Game {
+	if team != teamRed && team != teamBlue {
+		return game
+	}
+	if game.Teams == nil {
+		game.Teams = &types.Teams{}
+	}
+
+	if team == teamRed {
+		game.Teams.Red.Kills++
+	} else {
+		game.Teams.Blue.Kills++
+	}
+
+	return game
+}
+
+func (p *Parser) buildTeamRosters(game types.Game) types.Game {
+	for _, player := range game.PlayerList {
+		if player.Team != teamRed && player.Team != teamBlue {
+			continue
+		}
+		if game.Teams == nil {
+			game.Teams = &types.Teams{}
+		}
+
+		if player.Team == teamRed {
+			game.Teams.Red.Players = append(game.Teams.Red.Players, player.CurrentUsername)
+		} else {
+			game.Teams.Blue.Players = append(game.Teams.Blue.Players, player.CurrentUsername)
+		}
+	}
+
+	if game.Teams != nil {
+		if game.Teams.Red.Players == nil {
+			game.Teams.Red.Players = []string{}
+		}
+		if game.Teams.Blue.Players == nil {
+			game.Teams.Blue.Players = []string{}
+		}
+	}
+
+	return game
+}
+
+func (p *Parser) kdRatio(frags, deaths int) float64 {
+	if deaths == 0 {
+		return float64(frags)
+	}
+	return math.Round(float64(frags)/float64(deaths)*100) / 100
+}
+
+func (p *Parser) applyScore(score types.Score, game types.Game) types.Game {
+	// A client shows up once on the final scoreboard, keep its latest score
+	for i, existingScore := range game.Scoreboard {
+		if existingScore.ClientID == score.ClientID {
+			game.Scoreboard[i] = score
+			return game
+		}
+	}
+
+	game.Scoreboard = append(game.Scoreboard, score)
+
+	return game
+}
+
+func (p *Parser) checkScoreboard(game types.Game) []types.Mismatch {
+	if game.Metadata != nil && !scoredByFrags[game.Metadata.GameTypeID] {
+		return nil
+	}
+
+	var mismatches []types.Mismatch
+	for _, score := range game.Scoreboard {
+		player, ok := p.findScoredPlayer(score, game)
+		if !ok {
+			continue
+		}
+		// The server score takes suicides and world deaths off, as Score does
+		if player.Score != score.Score {
+			mismatches = append(mismatches, types.Mismatch{
+				Player:        player.CurrentUsername,
+				UserID:        player.UserID,
+				ComputedKills: player.Kills,
+				ComputedScore: player.Score,
+				ServerScore:   score.Score,
+			})
+		}
+	}
+	return mismatches
+}
+
+func (p *Parser) findScoredPlayer(score types.Score, game types.Game) (types.Player, bool) {
+	for _, player := range game.PlayerList {
+		if player.UserID == score.ClientID {
+			return player, true
+		}
+	}
+	for _, player := range game.PlayerList {
+		if player.CurrentUsername == score.Name {
+			return player, true
+		}
 	}
+	return types.Player{}, false
+}
 
+func (p *Parser) applyUserInfo(userID, currentUsername string, game types.Game) types.Game {
 	newPlayer := types.Player{
 		CurrentUsername: currentUsername,
 		UserID:          userID,
+		KillsByMeans:    make(map[string]int),
+		DeathsByMeans:   make(map[string]int),
 	}
 
 	// Check if player is already in the game
 	for i, existingPlayer := range game.PlayerList {
-		if existingPlayer.UserID == userID {
+		if existingPlayer.UserID == userID && !existingPlayer.Disconnected {
 			// If the player has changed their username, update the player struct
 			if existingPlayer.CurrentUsername != currentUsername {
 				game.PlayerList[i].PreviousUsernames = append(game.PlayerList[i].PreviousUsernames, existingPlayer.CurrentUsername)
 				game.PlayerList[i].CurrentUsername = currentUsername
 			}
-			return game, nil
+			return game
 		}
 	}
 
-	// Check if player with the same username exists in the game
+	// Check if a disconnected player with the same username exists in the game
 	for i, existingPlayer := range game.PlayerList {
-		if existingPlayer.CurrentUsername == currentUsername {
+		if existingPlayer.CurrentUsername == currentUsername && existingPlayer.Disconnected {
 			// If the player has reconnected with a new userID, update the player struct
 			game.PlayerList[i].UserID = userID
-			return game, nil
+			game.PlayerList[i].Disconnected = false
+			return game
 		}
 	}
 
 	// If not, add player to the game
 	game.PlayerList = append(game.PlayerList, newPlayer)
 
-	return game, nil
+	return game
 }
 
-func (p *Parser) extractUserDetails(line string) (userId string, username string, err error) {
-	parts := strings.Split(line, "\\")
-	if len(parts) < 2 {
-		return "", "", fmt.Errorf("could not parse line: %s", line)
-	}
-
-	username = parts[1]
+func (p *Parser) applyKill(kill types.Kill, game types.Game) types.Game {
+	// Kills are attributed by client id, names are only used for display
+	killerIndex := p.findPlayer(kill.KillerID, game)
+	victimIndex := p.findPlayer(kill.VictimID, game)
+	byWorld := kill.KillerID == worldID
+	suicide := kill.KillerID == kill.VictimID
 
-	r := regexp.MustCompile(`.*ClientUserinfoChanged: (.*\d+)`)
-	matches := r.FindStringSubmatch(parts[0])
-	if len(matches) < 2 {
-		return "", "", fmt.Errorf("could not parse userId: %s", parts[0])
+	if byWorld || suicide {
+		if victimIndex >= 0 && game.PlayerList[victimIndex].Kills > 0 {
+			game.PlayerList[victimIndex].Kills--
+		}
+	} else if killerIndex >= 0 {
+		game.PlayerList[killerIndex].Kills++
+		game.PlayerList[killerIndex].Frags++
+		game.PlayerList[killerIndex].KillsByMeans[kill.Means]++
+		game = p.applyTeamKill(game.PlayerList[killerIndex].Team, game)
 	}
-	userId = strings.TrimSpace(matches[1])
-	return userId, username, nil
-}
 
-func (p *Parser) processKillLine(line string, game types.Game) (types.Game, error) {
-	killer, killed, means, err := p.extractKillDetails(line)
-	if err != nil {
-		p.logger.Error("error extracting kill line", zap.Error(err))
-		return game, err
+	if victimIndex >= 0 {
+		game.PlayerList[victimIndex].Deaths++
+		game.PlayerList[victimIndex].DeathsByMeans[kill.Means]++
+		if byWorld {
+			game.PlayerList[victimIndex].WorldDeaths++
+		} else if suicide {
+			game.PlayerList[victimIndex].Suicides++
+		}
 	}
 
-	if killer == worldKiller || killer == killed {
-		for i, player := range game.PlayerList {
-			if player.CurrentUsername == killed && player.Kills > 0 {
-				game.PlayerList[i].Kills--
-				break
-			}
-		}
-	} else {
-		for i, player := range game.PlayerList {
-			if player.CurrentUsername == killer {
-				game.PlayerList[i].Kills++
-				break
-			}
-		}
+	if !byWorld {
+		game = p.recordKill(killerIndex, victimIndex, kill, game)
 	}
 
 	game.TotalKills++
-	game.KillsByMeans[means]++
+	game.KillsByMeans[kill.Means]++
 
-	return game, nil
+	return game
 }
 
-func (p *Parser) extractKillDetails(line string) (killer, killed, means string, err error) {
-	parts := strings.Split(line, " killed ")
-	if len(parts) != 2 {
-		return "", "", "", fmt.Errorf("could not parse line: %s", line)
-	}
-
-	r := regexp.MustCompile(`.*Kill: \d+ \d+ \d+: (.*)`)
-	matches := r.FindStringSubmatch(parts[0])
-	if len(matches) < 2 {
-		return "", "", "", fmt.Errorf("could not parse killer: %s", parts[0])
-	}
-	killer = strings.TrimSpace(matches[1])
-
-	parts = strings.Split(parts[1], " by ")
-	if len(parts) != 2 {
-		return "", "", "", fmt.Errorf("could not parse line: %s", line)
+// recordKill counts a kill in the kill matrix. Kills between known players are
+// kept by player, as a player may take a name that another one gave up, and are
+// named once the game ends. Other kills are kept under the names of the line.
+func (p *Parser) recordKill(killerIndex, victimIndex int, kill types.Kill, game types.Game) types.Game {
+	if killerIndex >= 0 && victimIndex >= 0 {
+		killer := &game.PlayerList[killerIndex]
+		if killer.KillsByVictim == nil {
+			killer.KillsByVictim = make(map[int]int)
+		}
+		killer.KillsByVictim[victimIndex]++
+		return game
 	}
 
-	killed = strings.TrimSpace(parts[0])
-	means = strings.TrimSpace(parts[1])
-
-	return killer, killed, means, nil
+	killer := p.displayName(killerIndex, kill.Killer, game)
+	victim := p.displayName(victimIndex, kill.Victim, game)
+	countKills(game.KillMatrixByID, kill.KillerID, kill.VictimID, 1)
+	countKills(game.KillMatrix, killer, victim, 1)
+	return game
 }
 
-func (p *Parser) isInitGameLine(line string) bool {
-	pattern := `\d+:\d+ InitGame`
-	r := regexp.MustCompile(pattern)
-	matches := r.FindAllString(line, -1)
-	return len(matches) > 0
+func (p *Parser) findPlayer(userID string, game types.Game) int {
+	for i, player := range game.PlayerList {
+		if player.UserID == userID && !player.Disconnected {
+			return i
+		}
+	}
+	return -1
 }
 
-func (p *Parser) isKillLine(line string) bool {
-	pattern := `\d+:\d+ Kill`
-	r := regexp.MustCompile(pattern)
-	matches := r.FindAllString(line, -1)
-	return len(matches) > 0
+func (p *Parser) displayName(playerIndex int, username string, game types.Game) string {
+	if playerIndex < 0 {
+		return username
+	}
+	return game.PlayerList[playerIndex].CurrentUsername
 }
 
-func (p *Parser) isUserInfoLine(line string) bool {
-	pattern := `\d+:\d+ ClientUserinfoChanged`
-	r := regexp.MustCompile(pattern)
-	matches := r.FindAllString(line, -1)
-	return len(matches) > 0
+func (p *Parser) applyDisconnect(userID string, game types.Game) types.Game {
+	playerIndex := p.findPlayer(userID, game)
+	if playerIndex >= 0 {
+		game.PlayerList[playerIndex].Disconnected = true
+	}
+	return game
 }
@@ -1,12 +1,31 @@
 package parser
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
 	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
 )
 
+// parseGameLines parses lines as the lines of game 1, as ParseStream would.
+func parseGameLines(p Parser, lines []string) (types.Game, error) {
+	chunk := gameChunk{gameNumber: 1, lines: lines}
+	for i := range lines {
+		chunk.positions = append(chunk.positions, position{line: i + 1})
+	}
+	return p.parseGame(chunk)
+}
+
 func TestParse(t *testing.T) {
 	p := NewParser(nil)
 
@@ -50,7 +69,7 @@ func TestParse(t *testing.T) {
 	}
 }
 
-func TestProcessNewGame(t *testing.T) {
+func TestParseGame(t *testing.T) {
 	p := NewParser(nil)
 
 	tests := []struct {
@@ -66,7 +85,7 @@ func TestProcessNewGame(t *testing.T) {
 			gameLines: []string{
 				"20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
 				"20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: Isgalamido killed Dono da Bola by MOD_TRIGGER_HURT",
+				"20:44 Kill: 2 3 22: Isgalamido killed Dono da Bola by MOD_TRIGGER_HURT",
 			},
 			expectedKills: map[string]int{
 				"Isgalamido": 1,
@@ -85,7 +104,7 @@ func TestProcessNewGame(t *testing.T) {
 			gameLines: []string{
 				"20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
 				"20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+				"20:44 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
 			},
 			expectedKills: map[string]int{},
 			expectedPlayers: []string{
@@ -102,7 +121,7 @@ func TestProcessNewGame(t *testing.T) {
 			gameLines: []string{
 				"20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
 				"20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: Dono da Bola killed Dono da Bola by MOD_ROCKET_SPLASH",
+				"20:44 Kill: 3 3 7: Dono da Bola killed Dono da Bola by MOD_ROCKET_SPLASH",
 			},
 			expectedKills: map[string]int{},
 			expectedPlayers: []string{
@@ -119,7 +138,7 @@ func TestProcessNewGame(t *testing.T) {
 			gameLines: []string{
 				"20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
 				"20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
+				"20:44 Kill: 2 3 7: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
 				"20:42 ClientUserinfoChanged: 2 n\\NewIsgalamido\\t\\0",
 			},
 			expectedKills: map[string]int{
@@ -139,10 +158,10 @@ func TestProcessNewGame(t *testing.T) {
 			gameLines: []string{
 				"20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
 				"20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
+				"20:44 Kill: 2 3 7: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
 				"20:44 ClientDisconnect: 2",
 				"20:40 ClientUserinfoChanged: 4 n\\Isgalamido\\t\\0",
-				"20:44 Kill: 1022 2 22: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
+				"20:44 Kill: 4 3 7: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
 			},
 			expectedKills: map[string]int{
 				"Isgalamido": 2,
@@ -159,7 +178,7 @@ func TestProcessNewGame(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		game, _ := p.processNewGame(1, test.gameLines)
+		game, _ := parseGameLines(p, test.gameLines)
 		if !reflect.DeepEqual(game.Kills, test.expectedKills) {
 			t.Errorf("%s: Expected kills %v, got %v", test.description, test.expectedKills, game.Kills)
 		}
@@ -175,7 +194,7 @@ func TestProcessNewGame(t *testing.T) {
 	}
 }
 
-func TestProcessUserInfoLine(t *testing.T) {
+func TestApplyUserInfo(t *testing.T) {
 	p := NewParser(nil)
 
 	tests := []struct {
@@ -219,13 +238,15 @@ func TestProcessUserInfoLine(t *testing.T) {
 			game.PlayerList = append(game.PlayerList, player)
 		}
 		if test.previousPlayerID != "" {
-			player := types.Player{CurrentUsername: test.expectedUsername, UserID: test.previousPlayerID}
+			player := types.Player{CurrentUsername: test.expectedUsername, UserID: test.previousPlayerID, Disconnected: true}
 			game.PlayerList = append(game.PlayerList, player)
 		}
-		game, err := p.processUserInfoLine(test.line, game)
+		token, _ := tokenize(test.line)
+		userID, username, _, err := parseUserDetails(token.args)
 		if err != nil {
 			t.Errorf("%s: Unexpected error: %v", test.description, err)
 		}
+		game = p.applyUserInfo(userID, username, game)
 		if test.previousUsername != "" {
 			if game.PlayerList[0].PreviousUsernames[0] != test.previousUsername {
 				t.Errorf("%s: Expected previous username %v, got %v", test.description, test.previousUsername, game.PlayerList[0].PreviousUsernames[0])
@@ -239,7 +260,7 @@ func TestProcessUserInfoLine(t *testing.T) {
 	}
 }
 
-func TestProcessKillLine(t *testing.T) {
+func TestApplyKill(t *testing.T) {
 	p := NewParser(nil)
 
 	tests := []struct {
@@ -266,18 +287,19 @@ func TestProcessKillLine(t *testing.T) {
 
 	for _, test := range tests {
 		game := p.newGame()
-		game, err := p.processKillLine(test.line, game)
+		token, _ := tokenize(test.line)
+		kill, err := parseKill(token.args)
 		if err != nil {
 			t.Errorf("%s: Unexpected error: %v", test.description, err)
 		}
+		game = p.applyKill(kill, game)
 		if game.TotalKills != test.expectedTotalKills {
 			t.Errorf("%s: Expected total kills %v, got %v", test.description, test.expectedTotalKills, game.TotalKills)
 		}
 	}
 }
 
-func TestExtractUserDetails(t *testing.T) {
-	p := NewParser(nil)
+func TestParseUserDetails(t *testing.T) {
 	tests := []struct {
 		description  string
 		line         string
@@ -299,7 +321,8 @@ func TestExtractUserDetails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		id, name, err := p.extractUserDetails(test.line)
+		token, _ := tokenize(test.line)
+		id, name, _, err := parseUserDetails(token.args)
 		if err != nil {
 			t.Errorf("%s: Unexpected error: %v", test.description, err)
 		}
@@ -312,8 +335,7 @@ func TestExtractUserDetails(t *testing.T) {
 	}
 }
 
-func TestExtractKillDetails(t *testing.T) {
-	p := NewParser(nil)
+func TestParseKill(t *testing.T) {
 	tests := []struct {
 		description    string
 		line           string
@@ -342,145 +364,1805 @@ func TestExtractKillDetails(t *testing.T) {
 			expectedKilled: "Isgalamido",
 			expectedMeans:  "MOD_ROCKET",
 		},
+		{
+			description:    "kill with by in names",
+			line:           "20:34 Kill: 3 2 7: Stand by Me killed Mr by Night by MOD_ROCKET",
+			expectedKiller: "Stand by Me",
+			expectedKilled: "Mr by Night",
+			expectedMeans:  "MOD_ROCKET",
+		},
 	}
 
 	for _, test := range tests {
-		killer, killed, means, err := p.extractKillDetails(test.line)
+		token, _ := tokenize(test.line)
+		kill, err := parseKill(token.args)
 		if err != nil {
 			t.Errorf("%s: Unexpected error: %v", test.description, err)
 		}
-		if killer != test.expectedKiller {
-			t.Errorf("%s: Expected killer %v, got %v", test.description, test.expectedKiller, killer)
+		if kill.Killer != test.expectedKiller {
+			t.Errorf("%s: Expected killer %v, got %v", test.description, test.expectedKiller, kill.Killer)
 		}
-		if killed != test.expectedKilled {
-			t.Errorf("%s: Expected killed %v, got %v", test.description, test.expectedKilled, killed)
+		if kill.Victim != test.expectedKilled {
+			t.Errorf("%s: Expected killed %v, got %v", test.description, test.expectedKilled, kill.Victim)
 		}
-		if means != test.expectedMeans {
-			t.Errorf("%s: Expected means %v, got %v", test.description, test.expectedMeans, means)
+		if kill.Means != test.expectedMeans {
+			t.Errorf("%s: Expected means %v, got %v", test.description, test.expectedMeans, kill.Means)
 		}
 	}
 }
 
-func TestIsInitGameLine(t *testing.T) {
-	p := NewParser(nil)
+func TestTokenizeKind(t *testing.T) {
 	tests := []struct {
 		description string
 		line        string
-		expected    bool
+		expected    types.EventKind
 	}{
 		{
 			description: "init game line",
 			line:        "20:34 InitGame: \\sv_floodProtect\\1\\sv_maxPing\\0",
-			expected:    true,
+			expected:    types.EventInitGame,
 		},
 		{
 			description: "kill line",
 			line:        "20:34 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
-			expected:    false,
+			expected:    types.EventKill,
 		},
 		{
 			description: "client user info changed line",
 			line:        "20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-			expected:    false,
+			expected:    types.EventClientUserinfoChanged,
+		},
+	}
+
+	for _, test := range tests {
+		token, ok := tokenize(test.line)
+		if !ok || token.kind != string(test.expected) {
+			t.Errorf("%s: Expected %v, got %v", test.description, test.expected, token.kind)
+		}
+	}
+}
+
+func TestFormatGameNumber(t *testing.T) {
+	p := &Parser{}
+
+	tests := []struct {
+		description string
+		gameNumber  int
+		expected    string
+	}{
+		{
+			description: "game number 1",
+			gameNumber:  1,
+			expected:    "game_1",
+		},
+		{
+			description: "game number 10",
+			gameNumber:  10,
+			expected:    "game_10",
 		},
 	}
 
 	for _, test := range tests {
-		result := p.isInitGameLine(test.line)
+		result := p.formatGameNumber(test.gameNumber)
 		if result != test.expected {
-			t.Errorf("%s: Expected %v, got %v", test.description, test.expected, result)
+			t.Errorf("%s: Expected %s, got %s", test.description, test.expected, result)
 		}
 	}
 }
 
-func TestIsKillLine(t *testing.T) {
+func TestEvents(t *testing.T) {
 	p := NewParser(nil)
+
+	lines := []string{
+		"  0:00 ------------------------------------------------------------",
+		"  0:00 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm17",
+		" 20:34 ClientConnect: 2",
+		" 20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0\\model\\xian/default",
+		" 20:37 ClientBegin: 2",
+		" 20:40 Item: 2 weapon_rocketlauncher",
+		" 22:06 Kill: 2 3 7: Isgalamido killed Mocinha by MOD_ROCKET_SPLASH",
+		"981:21 say: Oootsimo: team red",
+		" 10:12 red:8  blue:6",
+		" 16:41 score: 47  ping: 5  client: 5 Oootsimo",
+		" 15:00 Exit: Timelimit hit.",
+		" 20:37 ClientDisconnect: 2",
+		" 20:37 ShutdownGame:",
+	}
+
 	tests := []struct {
 		description string
-		line        string
-		expected    bool
+		index       int
+		expected    types.Event
 	}{
 		{
-			description: "init game line",
-			line:        "20:34 InitGame: \\sv_floodProtect\\1\\sv_maxPing\\0",
-			expected:    false,
+			description: "init game",
+			index:       0,
+			expected: types.Event{
+				Kind: types.EventInitGame,
+				Game: 1,
+				Line: 2,
+				Time: 0,
+				ServerVars: map[string]string{
+					"sv_hostname": "Code Miner Server",
+					"g_gametype":  "0",
+					"mapname":     "q3dm17",
+				},
+			},
 		},
 		{
-			description: "kill line",
-			line:        "20:34 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
-			expected:    true,
+			description: "client connect",
+			index:       1,
+			expected:    types.Event{Kind: types.EventClientConnect, Game: 1, Line: 3, Time: 1234, ClientID: "2"},
 		},
 		{
-			description: "client user info changed line",
-			line:        "20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-			expected:    false,
+			description: "client user info changed",
+			index:       2,
+			expected:    types.Event{Kind: types.EventClientUserinfoChanged, Game: 1, Line: 4, Time: 1234, ClientID: "2", Username: "Isgalamido", Userinfo: &types.Userinfo{Name: "Isgalamido", Team: "free", Model: "xian/default"}},
+		},
+		{
+			description: "client begin",
+			index:       3,
+			expected:    types.Event{Kind: types.EventClientBegin, Game: 1, Line: 5, Time: 1237, ClientID: "2"},
+		},
+		{
+			description: "item",
+			index:       4,
+			expected:    types.Event{Kind: types.EventItem, Game: 1, Line: 6, Time: 1240, ClientID: "2", Item: "weapon_rocketlauncher"},
+		},
+		{
+			description: "kill",
+			index:       5,
+			expected: types.Event{
+				Kind: types.EventKill,
+				Game: 1,
+				Line: 7,
+				Time: 1326,
+				Kill: &types.Kill{
+					KillerID: "2",
+					VictimID: "3",
+					MeansID:  "7",
+					Killer:   "Isgalamido",
+					Victim:   "Mocinha",
+					Means:    "MOD_ROCKET_SPLASH",
+				},
+			},
+		},
+		{
+			description: "say",
+			index:       6,
+			expected:    types.Event{Kind: types.EventSay, Game: 1, Line: 8, Time: 58881, Username: "Oootsimo", Message: "team red"},
+		},
+		{
+			description: "team score",
+			index:       7,
+			expected:    types.Event{Kind: types.EventTeamScore, Game: 1, Line: 9, Time: 612, TeamScore: &types.TeamScore{Red: 8, Blue: 6}},
+		},
+		{
+			description: "score",
+			index:       8,
+			expected: types.Event{
+				Kind:     types.EventScore,
+				Game:     1,
+				Line:     10,
+				Time:     1001,
+				ClientID: "5",
+				Username: "Oootsimo",
+				Score:    &types.Score{ClientID: "5", Name: "Oootsimo", Score: 47, Ping: 5},
+			},
+		},
+		{
+			description: "exit",
+			index:       9,
+			expected:    types.Event{Kind: types.EventExit, Game: 1, Line: 11, Time: 900, Message: "Timelimit hit."},
+		},
+		{
+			description: "client disconnect",
+			index:       10,
+			expected:    types.Event{Kind: types.EventClientDisconnect, Game: 1, Line: 12, Time: 1237, ClientID: "2"},
+		},
+		{
+			description: "shutdown game",
+			index:       11,
+			expected:    types.Event{Kind: types.EventShutdownGame, Game: 1, Line: 13, Time: 1237},
 		},
 	}
 
+	events, err := p.Events(lines)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != len(tests) {
+		t.Fatalf("Expected %v events, got %v", len(tests), len(events))
+	}
+
 	for _, test := range tests {
-		result := p.isKillLine(test.line)
-		if result != test.expected {
-			t.Errorf("%s: Expected %v, got %v", test.description, test.expected, result)
+		if !reflect.DeepEqual(events[test.index], test.expected) {
+			t.Errorf("%s: Expected %+v, got %+v", test.description, test.expected, events[test.index])
+		}
+	}
+}
+
+func TestParseGameZero(t *testing.T) {
+	p := NewParser(nil)
+
+	tests := []struct {
+		description   string
+		arrayLines    []string
+		expectedGames []string
+	}{
+		{
+			description: "lines before first game",
+			arrayLines: []string{
+				"  0:00 ------------------------------------------------------------",
+				"  0:00 InitGame: \\sv_floodProtect\\1\\sv_maxPing\\0",
+			},
+			expectedGames: []string{"game_0", "game_1"},
+		},
+		{
+			description:   "no lines",
+			arrayLines:    []string{},
+			expectedGames: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		games, err := p.Parse(test.arrayLines)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if len(games.Games) != len(test.expectedGames) {
+			t.Errorf("%s: Expected games %v, got %v", test.description, test.expectedGames, games.Games)
+		}
+		for _, key := range test.expectedGames {
+			if _, ok := games.Games[key]; !ok {
+				t.Errorf("%s: Expected game %v in %v", test.description, key, games.Games)
+			}
 		}
 	}
 }
 
-func TestIsUserInfoLine(t *testing.T) {
+func TestExtractMetadata(t *testing.T) {
 	p := NewParser(nil)
+
 	tests := []struct {
 		description string
 		line        string
-		expected    bool
+		expected    types.GameMetadata
 	}{
 		{
-			description: "init game line",
-			line:        "20:34 InitGame: \\sv_floodProtect\\1\\sv_maxPing\\0",
-			expected:    false,
+			description: "free for all game",
+			line:        "  0:00 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\fraglimit\\20\\timelimit\\15\\capturelimit\\8\\version\\ioq3 1.36 linux-x86_64 Apr 12 2009\\protocol\\68\\mapname\\q3dm17",
+			expected: types.GameMetadata{
+				Map:          "q3dm17",
+				GameType:     "FFA",
+				GameTypeID:   0,
+				FragLimit:    20,
+				TimeLimit:    15,
+				CaptureLimit: 8,
+				Hostname:     "Code Miner Server",
+				Version:      "ioq3 1.36 linux-x86_64 Apr 12 2009",
+				Protocol:     68,
+			},
 		},
 		{
-			description: "kill line",
-			line:        "20:34 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
-			expected:    false,
+			description: "capture the flag game",
+			line:        "  0:00 InitGame: \\capturelimit\\8\\g_gametype\\4\\mapname\\q3tourney6_ctf",
+			expected: types.GameMetadata{
+				Map:          "q3tourney6_ctf",
+				GameType:     "CTF",
+				GameTypeID:   4,
+				CaptureLimit: 8,
+			},
 		},
 		{
-			description: "client user info changed line",
-			line:        "20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
-			expected:    true,
+			description: "unknown game type",
+			line:        "  0:00 InitGame: \\g_gametype\\9",
+			expected: types.GameMetadata{
+				GameType:   "Unknown (9)",
+				GameTypeID: 9,
+			},
 		},
 	}
 
 	for _, test := range tests {
-		result := p.isUserInfoLine(test.line)
-		if result != test.expected {
-			t.Errorf("%s: Expected %v, got %v", test.description, test.expected, result)
+		game, err := parseGameLines(p, []string{test.line})
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if game.Metadata == nil {
+			t.Errorf("%s: Expected metadata, got nil", test.description)
+			continue
+		}
+		metadata := *game.Metadata
+		metadata.ServerVars = nil
+		if !reflect.DeepEqual(metadata, test.expected) {
+			t.Errorf("%s: Expected metadata %+v, got %+v", test.description, test.expected, metadata)
 		}
 	}
 }
 
-func TestFormatGameNumber(t *testing.T) {
-	p := &Parser{}
+func TestGameEnd(t *testing.T) {
+	p := NewParser(nil)
 
 	tests := []struct {
-		description string
-		gameNumber  int
-		expected    string
+		description        string
+		gameLines          []string
+		expectedStartTime  types.Timestamp
+		expectedEndTime    types.Timestamp
+		expectedDuration   int
+		expectedExitReason string
+		expectedIncomplete bool
 	}{
 		{
-			description: "game number 1",
-			gameNumber:  1,
-			expected:    "game_1",
+			description: "game ended by fraglimit",
+			gameLines: []string{
+				"  1:47 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+				"  1:50 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 12:13 Exit: Fraglimit hit.",
+				" 12:13 score: 20  ping: 4  client: 2 Isgalamido",
+				" 12:13 ShutdownGame:",
+			},
+			expectedStartTime:  107,
+			expectedEndTime:    733,
+			expectedDuration:   626,
+			expectedExitReason: "Fraglimit hit",
+			expectedIncomplete: false,
 		},
 		{
-			description: "game number 10",
-			gameNumber:  10,
-			expected:    "game_10",
+			description: "game shut down without exit",
+			gameLines: []string{
+				"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+				"  1:47 ShutdownGame:",
+			},
+			expectedStartTime:  0,
+			expectedEndTime:    107,
+			expectedDuration:   107,
+			expectedExitReason: "",
+			expectedIncomplete: false,
+		},
+		{
+			description: "game cut off",
+			gameLines: []string{
+				" 20:37 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+				" 20:38 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 26:09 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+			},
+			expectedStartTime:  1237,
+			expectedEndTime:    1569,
+			expectedDuration:   332,
+			expectedExitReason: "",
+			expectedIncomplete: true,
 		},
 	}
 
 	for _, test := range tests {
-		result := p.formatGameNumber(test.gameNumber)
-		if result != test.expected {
-			t.Errorf("%s: Expected %s, got %s", test.description, test.expected, result)
+		game, err := parseGameLines(p, test.gameLines)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if game.StartTime != test.expectedStartTime {
+			t.Errorf("%s: Expected start time %v, got %v", test.description, test.expectedStartTime, game.StartTime)
+		}
+		if game.EndTime != test.expectedEndTime {
+			t.Errorf("%s: Expected end time %v, got %v", test.description, test.expectedEndTime, game.EndTime)
+		}
+		if game.Duration != test.expectedDuration {
+			t.Errorf("%s: Expected duration %v, got %v", test.description, test.expectedDuration, game.Duration)
+		}
+		if game.ExitReason != test.expectedExitReason {
+			t.Errorf("%s: Expected exit reason %v, got %v", test.description, test.expectedExitReason, game.ExitReason)
+		}
+		if game.Incomplete != test.expectedIncomplete {
+			t.Errorf("%s: Expected incomplete %v, got %v", test.description, test.expectedIncomplete, game.Incomplete)
+		}
+	}
+}
+
+func TestScoreboard(t *testing.T) {
+	p := NewParser(nil)
+
+	tests := []struct {
+		description        string
+		gameLines          []string
+		expectedScoreboard []types.Score
+		expectedMismatches []types.Mismatch
+	}{
+		{
+			description: "scoreboard matching computed scores",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 21:00 score: 1  ping: 4  client: 2 Isgalamido",
+				" 21:00 score: 0  ping: 9  client: 3 Dono da Bola",
+			},
+			expectedScoreboard: []types.Score{
+				{ClientID: "2", Name: "Isgalamido", Score: 1, Ping: 4},
+				{ClientID: "3", Name: "Dono da Bola", Score: 0, Ping: 9},
+			},
+			expectedMismatches: nil,
+		},
+		{
+			description: "world deaths taken off the server score",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 20:44 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+				" 21:00 score: 0  ping: 4  client: 2 Isgalamido",
+				" 21:00 score: -1  ping: 9  client: 3 Dono da Bola",
+			},
+			expectedScoreboard: []types.Score{
+				{ClientID: "2", Name: "Isgalamido", Score: 0, Ping: 4},
+				{ClientID: "3", Name: "Dono da Bola", Score: -1, Ping: 9},
+			},
+			expectedMismatches: nil,
+		},
+		{
+			description: "scoreboard disagreeing with computed scores",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 20:45 Kill: 3 3 7: Dono da Bola killed Dono da Bola by MOD_ROCKET_SPLASH",
+				" 21:00 score: 3  ping: 4  client: 2 Isgalamido",
+				" 21:00 score: -1  ping: 9  client: 3 Dono da Bola",
+			},
+			expectedScoreboard: []types.Score{
+				{ClientID: "2", Name: "Isgalamido", Score: 3, Ping: 4},
+				{ClientID: "3", Name: "Dono da Bola", Score: -1, Ping: 9},
+			},
+			expectedMismatches: []types.Mismatch{
+				{Player: "Isgalamido", UserID: "2", ComputedKills: 1, ComputedScore: 1, ServerScore: 3},
+			},
+		},
+		{
+			description: "CTF scores left unchecked",
+			gameLines: []string{
+				"  0:00 InitGame: \\g_gametype\\4\\mapname\\q3ctf1",
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\1",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\2",
+				" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 21:00 score: 77  ping: 4  client: 2 Isgalamido",
+				" 21:00 score: 15  ping: 9  client: 3 Dono da Bola",
+			},
+			expectedScoreboard: []types.Score{
+				{ClientID: "2", Name: "Isgalamido", Score: 77, Ping: 4},
+				{ClientID: "3", Name: "Dono da Bola", Score: 15, Ping: 9},
+			},
+			expectedMismatches: nil,
+		},
+		{
+			description: "no scoreboard",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+			},
+			expectedScoreboard: nil,
+			expectedMismatches: nil,
+		},
+	}
+
+	for _, test := range tests {
+		game, err := parseGameLines(p, test.gameLines)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(game.Scoreboard, test.expectedScoreboard) {
+			t.Errorf("%s: Expected scoreboard %v, got %v", test.description, test.expectedScoreboard, game.Scoreboard)
+		}
+		if !reflect.DeepEqual(game.Mismatches, test.expectedMismatches) {
+			t.Errorf("%s: Expected mismatches %v, got %v", test.description, test.expectedMismatches, game.Mismatches)
+		}
+	}
+}
+
+func TestTeams(t *testing.T) {
+	p := NewParser(nil)
+
+	tests := []struct {
+		description   string
+		gameLines     []string
+		expectedTeams *types.Teams
+	}{
+		{
+			description: "capture the flag game won by red",
+			gameLines: []string{
+				"  0:00 InitGame: \\g_gametype\\4\\mapname\\Q3TOURNEY6_CTF",
+				"  0:06 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\1\\model\\sarge",
+				"  0:07 ClientUserinfoChanged: 4 n\\Zeh\\t\\2\\model\\sarge/default",
+				"  0:08 ClientUserinfoChanged: 5 n\\Mal\\t\\3\\model\\sarge/default",
+				"  1:10 Kill: 3 4 10: Dono da Bola killed Zeh by MOD_RAILGUN",
+				"  1:15 Kill: 4 4 6: Zeh killed Zeh by MOD_ROCKET_SPLASH",
+				"  1:20 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+				" 10:12 Exit: Capturelimit hit.",
+				" 10:12 red:8  blue:6",
+			},
+			expectedTeams: &types.Teams{
+				Red:    types.Team{Players: []string{"Dono da Bola"}, Score: 8, Kills: 1},
+				Blue:   types.Team{Players: []string{"Zeh"}, Score: 6, Kills: 0},
+				Winner: "red",
+			},
+		},
+		{
+			description: "team game with tied score",
+			gameLines: []string{
+				"  0:00 InitGame: \\g_gametype\\4\\mapname\\Q3TOURNEY6_CTF",
+				"  0:06 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\1\\model\\sarge",
+				" 31:53 red:0  blue:0",
+			},
+			expectedTeams: &types.Teams{
+				Red:    types.Team{Players: []string{"Dono da Bola"}},
+				Blue:   types.Team{Players: []string{}},
+				Winner: "tie",
+			},
+		},
+		{
+			description: "free for all game",
+			gameLines: []string{
+				"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+				"  0:06 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0\\model\\sarge",
+			},
+			expectedTeams: nil,
+		},
+	}
+
+	for _, test := range tests {
+		game, err := parseGameLines(p, test.gameLines)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(game.Teams, test.expectedTeams) {
+			t.Errorf("%s: Expected teams %+v, got %+v", test.description, test.expectedTeams, game.Teams)
+		}
+	}
+}
+
+func TestDecodeUserinfo(t *testing.T) {
+	tests := []struct {
+		description   string
+		info          string
+		expected      types.Userinfo
+		expectedError bool
+	}{
+		{
+			description: "all known keys",
+			info:        "n\\Isgalamido\\t\\1\\model\\xian/default\\hmodel\\uriel/zael\\g_redteam\\Reds\\g_blueteam\\\\c1\\4\\c2\\5\\hc\\95\\w\\3\\l\\2\\tt\\1\\tl\\1",
+			expected: types.Userinfo{
+				Name:       "Isgalamido",
+				Team:       "red",
+				Model:      "xian/default",
+				HeadModel:  "uriel/zael",
+				RedTeam:    "Reds",
+				BlueTeam:   "",
+				Color1:     "4",
+				Color2:     "5",
+				Handicap:   95,
+				Wins:       3,
+				Losses:     2,
+				TeamTask:   1,
+				TeamLeader: true,
+			},
+		},
+		{
+			description: "unknown keys",
+			info:        "n\\Zeh\\t\\3\\skill\\5\\cg_predictItems\\1",
+			expected: types.Userinfo{
+				Name: "Zeh",
+				Team: "spectator",
+				Unknown: map[string]string{
+					"skill":           "5",
+					"cg_predictItems": "1",
+				},
+			},
+		},
+		{
+			description: "missing value",
+			info:        "n\\Zeh\\t",
+			expected: types.Userinfo{
+				Name:    "Zeh",
+				Unknown: map[string]string{"t": ""},
+			},
+		},
+		{
+			description: "invalid values",
+			info:        "n\\Zeh\\t\\5\\hc\\\\w\\abc\\model\\sarge",
+			expected: types.Userinfo{
+				Name:  "Zeh",
+				Model: "sarge",
+				Unknown: map[string]string{
+					"t":  "5",
+					"hc": "",
+					"w":  "abc",
+				},
+			},
+		},
+		{
+			description:   "missing name",
+			info:          "t\\0\\model\\sarge",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		userinfo, err := DecodeUserinfo(test.info)
+		if test.expectedError {
+			if err == nil {
+				t.Errorf("%s: Expected error, got nil", test.description)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(userinfo, test.expected) {
+			t.Errorf("%s: Expected userinfo %+v, got %+v", test.description, test.expected, userinfo)
 		}
 	}
 }
+
+func TestPlayerStats(t *testing.T) {
+	p := NewParser(nil)
+
+	gameLines := []string{
+		" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+		" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+		" 20:50 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+		" 20:55 Kill: 3 2 7: Dono da Bola killed Isgalamido by MOD_ROCKET_SPLASH",
+		" 21:02 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+		" 21:10 Kill: 2 2 7: Isgalamido killed Isgalamido by MOD_ROCKET_SPLASH",
+	}
+
+	tests := []struct {
+		description         string
+		username            string
+		expectedKills       int
+		expectedFrags       int
+		expectedDeaths      int
+		expectedSuicides    int
+		expectedWorldDeaths int
+		expectedScore       int
+		expectedKDRatio     float64
+	}{
+		{
+			description:         "player with suicide",
+			username:            "Isgalamido",
+			expectedKills:       1,
+			expectedFrags:       2,
+			expectedDeaths:      2,
+			expectedSuicides:    1,
+			expectedWorldDeaths: 0,
+			expectedScore:       1,
+			expectedKDRatio:     1,
+		},
+		{
+			description:         "player with world death",
+			username:            "Dono da Bola",
+			expectedKills:       0,
+			expectedFrags:       1,
+			expectedDeaths:      3,
+			expectedSuicides:    0,
+			expectedWorldDeaths: 1,
+			expectedScore:       0,
+			expectedKDRatio:     0.33,
+		},
+	}
+
+	game, err := parseGameLines(p, gameLines)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, test := range tests {
+		var player types.Player
+		for _, existingPlayer := range game.PlayerList {
+			if existingPlayer.CurrentUsername == test.username {
+				player = existingPlayer
+			}
+		}
+		if player.Kills != test.expectedKills {
+			t.Errorf("%s: Expected kills %v, got %v", test.description, test.expectedKills, player.Kills)
+		}
+		if player.Frags != test.expectedFrags {
+			t.Errorf("%s: Expected frags %v, got %v", test.description, test.expectedFrags, player.Frags)
+		}
+		if player.Deaths != test.expectedDeaths {
+			t.Errorf("%s: Expected deaths %v, got %v", test.description, test.expectedDeaths, player.Deaths)
+		}
+		if player.Suicides != test.expectedSuicides {
+			t.Errorf("%s: Expected suicides %v, got %v", test.description, test.expectedSuicides, player.Suicides)
+		}
+		if player.WorldDeaths != test.expectedWorldDeaths {
+			t.Errorf("%s: Expected world deaths %v, got %v", test.description, test.expectedWorldDeaths, player.WorldDeaths)
+		}
+		if player.Score != test.expectedScore {
+			t.Errorf("%s: Expected score %v, got %v", test.description, test.expectedScore, player.Score)
+		}
+		if player.KDRatio != test.expectedKDRatio {
+			t.Errorf("%s: Expected K/D ratio %v, got %v", test.description, test.expectedKDRatio, player.KDRatio)
+		}
+	}
+}
+
+func TestPlayerKillsByMeans(t *testing.T) {
+	p := NewParser(nil)
+
+	gameLines := []string{
+		" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+		" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+		" 20:50 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+		" 20:55 Kill: 3 2 7: Dono da Bola killed Isgalamido by MOD_ROCKET_SPLASH",
+		" 21:02 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+		" 21:10 Kill: 2 2 7: Isgalamido killed Isgalamido by MOD_ROCKET_SPLASH",
+	}
+
+	tests := []struct {
+		description           string
+		username              string
+		expectedKillsByMeans  map[string]int
+		expectedDeathsByMeans map[string]int
+	}{
+		{
+			description: "player with railgun kills",
+			username:    "Isgalamido",
+			expectedKillsByMeans: map[string]int{
+				"MOD_RAILGUN": 2,
+			},
+			expectedDeathsByMeans: map[string]int{
+				"MOD_ROCKET_SPLASH": 2,
+			},
+		},
+		{
+			description: "player with world death",
+			username:    "Dono da Bola",
+			expectedKillsByMeans: map[string]int{
+				"MOD_ROCKET_SPLASH": 1,
+			},
+			expectedDeathsByMeans: map[string]int{
+				"MOD_RAILGUN":      2,
+				"MOD_TRIGGER_HURT": 1,
+			},
+		},
+	}
+
+	game, err := parseGameLines(p, gameLines)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, test := range tests {
+		var player types.Player
+		for _, existingPlayer := range game.PlayerList {
+			if existingPlayer.CurrentUsername == test.username {
+				player = existingPlayer
+			}
+		}
+		if !reflect.DeepEqual(player.KillsByMeans, test.expectedKillsByMeans) {
+			t.Errorf("%s: Expected kills by means %v, got %v", test.description, test.expectedKillsByMeans, player.KillsByMeans)
+		}
+		if !reflect.DeepEqual(player.DeathsByMeans, test.expectedDeathsByMeans) {
+			t.Errorf("%s: Expected deaths by means %v, got %v", test.description, test.expectedDeathsByMeans, player.DeathsByMeans)
+		}
+	}
+}
+
+func TestKillMatrix(t *testing.T) {
+	p := NewParser(nil)
+
+	tests := []struct {
+		description        string
+		gameLines          []string
+		expectedKillMatrix map[string]map[string]int
+	}{
+		{
+			description: "kills between players",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 20:50 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 20:55 Kill: 3 2 7: Dono da Bola killed Isgalamido by MOD_ROCKET_SPLASH",
+				" 21:02 Kill: 1022 3 22: <world> killed Dono da Bola by MOD_TRIGGER_HURT",
+				" 21:10 Kill: 2 2 7: Isgalamido killed Isgalamido by MOD_ROCKET_SPLASH",
+			},
+			expectedKillMatrix: map[string]map[string]int{
+				"Isgalamido": {
+					"Dono da Bola": 2,
+					"Isgalamido":   1,
+				},
+				"Dono da Bola": {
+					"Isgalamido": 1,
+				},
+			},
+		},
+		{
+			description: "kills across username change",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+				" 20:40 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+				" 20:44 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+				" 20:45 ClientUserinfoChanged: 3 n\\Mocinha\\t\\0",
+				" 20:50 Kill: 2 3 10: Isgalamido killed Mocinha by MOD_RAILGUN",
+			},
+			expectedKillMatrix: map[string]map[string]int{
+				"Isgalamido": {
+					"Mocinha": 2,
+				},
+			},
+		},
+		{
+			description: "username taken over by another player",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 4 n\\Mal\\t\\0",
+				" 20:38 ClientUserinfoChanged: 2 n\\Foo\\t\\0",
+				" 20:39 ClientUserinfoChanged: 3 n\\Zeh\\t\\0",
+				" 20:40 Kill: 2 4 10: Foo killed Mal by MOD_RAILGUN",
+				" 20:41 ClientUserinfoChanged: 2 n\\Bar\\t\\0",
+				" 20:42 ClientUserinfoChanged: 3 n\\Foo\\t\\0",
+				" 20:43 Kill: 3 4 10: Foo killed Mal by MOD_RAILGUN",
+			},
+			expectedKillMatrix: map[string]map[string]int{
+				"Bar": {"Mal": 1},
+				"Foo": {"Mal": 1},
+			},
+		},
+		{
+			description: "no kills",
+			gameLines: []string{
+				" 20:37 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0",
+			},
+			expectedKillMatrix: map[string]map[string]int{},
+		},
+	}
+
+	for _, test := range tests {
+		game, err := parseGameLines(p, test.gameLines)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(game.KillMatrix, test.expectedKillMatrix) {
+			t.Errorf("%s: Expected kill matrix %v, got %v", test.description, test.expectedKillMatrix, game.KillMatrix)
+		}
+	}
+}
+
+func TestAmbiguousNames(t *testing.T) {
+	p := NewParser(nil)
+
+	content, err := os.ReadFile("testdata/ambiguous_names.log")
+	if err != nil {
+		t.Fatalf("Error reading test corpus: %v", err)
+	}
+
+	games, err := p.Parse(strings.Split(string(content), "\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		description      string
+		userID           string
+		expectedUsername string
+		expectedFrags    int
+		expectedDeaths   int
+		expectedSuicides int
+	}{
+		{
+			description:      "name containing killed",
+			userID:           "2",
+			expectedUsername: "Mr killed Nobody",
+			expectedFrags:    1,
+			expectedDeaths:   2,
+			expectedSuicides: 0,
+		},
+		{
+			description:      "name containing by",
+			userID:           "3",
+			expectedUsername: "Stand by Me",
+			expectedFrags:    1,
+			expectedDeaths:   1,
+			expectedSuicides: 0,
+		},
+		{
+			description:      "first of two players sharing a name",
+			userID:           "4",
+			expectedUsername: "Zeh",
+			expectedFrags:    1,
+			expectedDeaths:   1,
+			expectedSuicides: 0,
+		},
+		{
+			description:      "second of two players sharing a name",
+			userID:           "5",
+			expectedUsername: "Zeh",
+			expectedFrags:    0,
+			expectedDeaths:   2,
+			expectedSuicides: 1,
+		},
+		{
+			description:      "new player reusing a client id",
+			userID:           "3",
+			expectedUsername: "Dono da Bola",
+			expectedFrags:    1,
+			expectedDeaths:   0,
+			expectedSuicides: 0,
+		},
+	}
+
+	game := games.Games["game_1"]
+	if len(game.PlayerList) != len(tests) {
+		t.Fatalf("Expected %v players, got %v", len(tests), len(game.PlayerList))
+	}
+
+	for _, test := range tests {
+		var player *types.Player
+		for i, existingPlayer := range game.PlayerList {
+			if existingPlayer.UserID == test.userID && existingPlayer.CurrentUsername == test.expectedUsername {
+				player = &game.PlayerList[i]
+			}
+		}
+		if player == nil {
+			t.Errorf("%s: Expected player %v with id %v", test.description, test.expectedUsername, test.userID)
+			continue
+		}
+		if player.Frags != test.expectedFrags {
+			t.Errorf("%s: Expected frags %v, got %v", test.description, test.expectedFrags, player.Frags)
+		}
+		if player.Deaths != test.expectedDeaths {
+			t.Errorf("%s: Expected deaths %v, got %v", test.description, test.expectedDeaths, player.Deaths)
+		}
+		if player.Suicides != test.expectedSuicides {
+			t.Errorf("%s: Expected suicides %v, got %v", test.description, test.expectedSuicides, player.Suicides)
+		}
+	}
+
+	expectedKillMatrix := map[string]map[string]int{
+		"Mr killed Nobody": {"Stand by Me": 1},
+		"Stand by Me":      {"Mr killed Nobody": 1},
+		"Zeh":              {"Zeh": 2},
+		"Dono da Bola":     {"Mr killed Nobody": 1},
+	}
+	if !reflect.DeepEqual(game.KillMatrix, expectedKillMatrix) {
+		t.Errorf("Expected kill matrix %v, got %v", expectedKillMatrix, game.KillMatrix)
+	}
+
+	// Keyed by client id, the kill of the other Zeh is told apart from the suicide
+	expectedKillMatrixByID := map[string]map[string]int{
+		"2": {"3": 1},
+		"3": {"2": 2},
+		"4": {"5": 1},
+		"5": {"5": 1},
+	}
+	if !reflect.DeepEqual(game.KillMatrixByID, expectedKillMatrixByID) {
+		t.Errorf("Expected kill matrix by id %v, got %v", expectedKillMatrixByID, game.KillMatrixByID)
+	}
+
+	// Players sharing a name add up under that name
+	game = games.Games["game_2"]
+	expectedKills := map[string]int{"Zeh": 2}
+	if !reflect.DeepEqual(game.Kills, expectedKills) {
+		t.Errorf("Expected kills %v, got %v", expectedKills, game.Kills)
+	}
+	expectedKillMatrix = map[string]map[string]int{"Zeh": {"Mal": 2}}
+	if !reflect.DeepEqual(game.KillMatrix, expectedKillMatrix) {
+		t.Errorf("Expected kill matrix %v, got %v", expectedKillMatrix, game.KillMatrix)
+	}
+	expectedKillMatrixByID = map[string]map[string]int{"2": {"4": 1}, "3": {"4": 1}}
+	if !reflect.DeepEqual(game.KillMatrixByID, expectedKillMatrixByID) {
+		t.Errorf("Expected kill matrix by id %v, got %v", expectedKillMatrixByID, game.KillMatrixByID)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	lines := []string{
+		"  0:00 ------------------------------------------------------------",
+		"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:37 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:37 ShutdownGame:",
+		" 20:37 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:38 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:54 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+	}
+
+	tests := []struct {
+		description  string
+		stopAfter    int
+		expectedKeys []string
+		expectError  bool
+	}{
+		{
+			description:  "all games in order",
+			expectedKeys: []string{"game_0", "game_1", "game_2"},
+		},
+		{
+			description:  "handler error stops parsing",
+			stopAfter:    2,
+			expectedKeys: []string{"game_0", "game_1"},
+			expectError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		keys := []string{}
+		err := p.ParseStream(bufio.NewScanner(strings.NewReader(strings.Join(lines, "\n"))), func(key string, game types.Game) error {
+			keys = append(keys, key)
+			if len(keys) == test.stopAfter {
+				return errors.New("stop")
+			}
+			return nil
+		})
+		if test.expectError && err == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(keys, test.expectedKeys) {
+			t.Errorf("%s: Expected games %v, got %v", test.description, test.expectedKeys, keys)
+		}
+	}
+}
+
+func TestParseStreamLive(t *testing.T) {
+	lines := []string{
+		" 20:30 Kill: 2 3 10: Isgalamido killed Mocinha by MOD_RAILGUN",
+		" 20:31 ShutdownGame:",
+		" 20:32 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:33 ShutdownGame:",
+		" 20:34 InitGame: \\g_gametype\\0\\mapname\\q3dm6",
+		" 20:35 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+	}
+
+	tests := []struct {
+		description  string
+		live         bool
+		expectedKeys []string
+	}{
+		{
+			description:  "log read whole",
+			live:         false,
+			expectedKeys: []string{"game_0", "game_1", "game_2"},
+		},
+		{
+			description:  "live log read from the middle of a game",
+			live:         true,
+			expectedKeys: []string{"game_1"},
+		},
+	}
+
+	for _, test := range tests {
+		p := NewParser(zap.NewNop())
+		p.SetLive(test.live)
+
+		var keys []string
+		err := p.ParseStream(&sliceScanner{lines: lines}, func(key string, game types.Game) error {
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(keys, test.expectedKeys) {
+			t.Errorf("%s: Expected games %v, got %v", test.description, test.expectedKeys, keys)
+		}
+	}
+}
+
+func TestParseStreamShutdown(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	scanner := &sliceScanner{lines: []string{
+		"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:37 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:37 ShutdownGame:",
+		" 20:37 ------------------------------------------------------------",
+		" 20:37 ------------------------------------------------------------",
+	}}
+
+	linesRead := map[string]int{}
+	err := p.ParseStream(scanner, func(key string, game types.Game) error {
+		linesRead[key] = scanner.index
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The game must be handed over without waiting for the lines after ShutdownGame
+	expected := map[string]int{"game_1": 3}
+	if !reflect.DeepEqual(linesRead, expected) {
+		t.Errorf("Expected games handed over after lines %v, got %v", expected, linesRead)
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	content, err := os.ReadFile("testdata/ambiguous_names.log")
+	if err != nil {
+		t.Fatalf("Error reading test corpus: %v", err)
+	}
+	lines := []string{"  0:00 ------------------------------------------------------------"}
+	for i := 0; i < 50; i++ {
+		lines = append(lines, strings.Split(string(content), "\n")...)
+		lines = append(lines, benchmarkGameLines...)
+	}
+
+	var expectedKeys []string
+	var expectedGames []types.Game
+	err = p.ParseStream(&sliceScanner{lines: lines}, func(key string, game types.Game) error {
+		expectedKeys = append(expectedKeys, key)
+		expectedGames = append(expectedGames, game)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	badLines := append([]string{}, lines...)
+	badLines = append(badLines[:len(badLines)-5], " 1:00 Kill: 2 3: broken", " 1:01 ShutdownGame:")
+
+	tests := []struct {
+		description   string
+		lines         []string
+		workers       int
+		stopAfter     int
+		expectedCount int
+		expectError   bool
+	}{
+		{
+			description:   "one worker",
+			lines:         lines,
+			workers:       1,
+			expectedCount: len(expectedKeys),
+		},
+		{
+			description:   "several workers",
+			lines:         lines,
+			workers:       4,
+			expectedCount: len(expectedKeys),
+		},
+		{
+			description:   "one worker per CPU",
+			lines:         lines,
+			workers:       0,
+			expectedCount: len(expectedKeys),
+		},
+		{
+			description:   "handler error stops parsing",
+			lines:         lines,
+			workers:       4,
+			stopAfter:     10,
+			expectedCount: 10,
+			expectError:   true,
+		},
+		{
+			description:   "parse error in the last game",
+			lines:         badLines,
+			workers:       4,
+			expectedCount: len(expectedKeys) - 1,
+			expectError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		var keys []string
+		var games []types.Game
+		err := p.ParseConcurrent(&sliceScanner{lines: test.lines}, test.workers, func(key string, game types.Game) error {
+			keys = append(keys, key)
+			games = append(games, game)
+			if len(keys) == test.stopAfter {
+				return errors.New("stop")
+			}
+			return nil
+		})
+		if test.expectError && err == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+		if !test.expectError && err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+		}
+		if len(keys) != test.expectedCount {
+			t.Errorf("%s: Expected %d games, got %d", test.description, test.expectedCount, len(keys))
+			continue
+		}
+		if !reflect.DeepEqual(keys, expectedKeys[:test.expectedCount]) {
+			t.Errorf("%s: Expected games %v, got %v", test.description, expectedKeys[:test.expectedCount], keys)
+		}
+		if !reflect.DeepEqual(games, expectedGames[:test.expectedCount]) {
+			t.Errorf("%s: Expected the same games as ParseStream", test.description)
+		}
+	}
+}
+
+// lateScanner counts the lines scanned after the parser has returned.
+type lateScanner struct {
+	sliceScanner
+	returned atomic.Bool
+	late     atomic.Int32
+}
+
+func (s *lateScanner) Scan() bool {
+	if s.returned.Load() {
+		s.late.Add(1)
+	}
+	time.Sleep(time.Microsecond)
+	return s.sliceScanner.Scan()
+}
+
+func TestParseConcurrentStops(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	// The first game fails while the next ones are still being read
+	lines := []string{
+		"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 1:00 Kill: 2 3: broken",
+		" 1:01 ShutdownGame:",
+	}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, benchmarkGameLines...)
+	}
+
+	for i := 0; i < 20; i++ {
+		scanner := &lateScanner{sliceScanner: sliceScanner{lines: lines}}
+		err := p.ParseConcurrent(scanner, 2, func(key string, game types.Game) error {
+			return nil
+		})
+		scanner.returned.Store(true)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		time.Sleep(time.Millisecond)
+		if late := scanner.late.Load(); late != 0 {
+			t.Fatalf("Expected no lines scanned after returning, got %d", late)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	lines := []string{
+		"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:35 ClientUserinfoChanged: n\\Mocinha\\t\\0",
+		" 20:36 ClientUserinfoChanged: 3 n\\Mocinha\\t\\0",
+		" 20:40 Kill: 2 3 7: Isgalamido killed Mocinha by",
+		" 20:41 Kill: 2 3 7: Isgalamido killed Mocinha by MOD_ROCKET_SPLASH",
+		" 20:50 ShutdownGame:",
+		" 20:50 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:51 score: 20  ping: 4",
+	}
+
+	strict := NewParser(zap.NewNop())
+	_, err := strict.Parse(lines)
+	if err == nil {
+		t.Errorf("Expected error in strict mode, got nil")
+	}
+
+	lenient := NewParser(zap.NewNop())
+	lenient.SetLenient(true)
+	games, err := lenient.Parse(lines)
+	if err != nil {
+		t.Fatalf("Unexpected error in lenient mode: %v", err)
+	}
+
+	if games.Games["game_1"].Kills["Isgalamido"] != 1 {
+		t.Errorf("Expected the valid kill to be counted, got %v", games.Games["game_1"].Kills)
+	}
+	if !reflect.DeepEqual(games.Games["game_1"].Players, []string{"Isgalamido", "Mocinha"}) {
+		t.Errorf("Expected the valid players to be kept, got %v", games.Games["game_1"].Players)
+	}
+
+	expected := []types.Diagnostic{
+		{Line: 3, Game: "game_1", Raw: lines[2], Reason: "could not parse userId: n\\Mocinha\\t\\0"},
+		{Line: 5, Game: "game_1", Raw: lines[4], Reason: "could not parse means: 2 3 7: Isgalamido killed Mocinha by"},
+		{Line: 9, Game: "game_2", Raw: lines[8], Reason: "could not parse score: 20  ping: 4"},
+	}
+	diagnostics := games.Diagnostics()
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("Expected diagnostics %+v, got %+v", expected, diagnostics)
+	}
+
+	events, err := lenient.Events(lines)
+	if err != nil {
+		t.Fatalf("Unexpected error emitting events in lenient mode: %v", err)
+	}
+	if len(events) != len(lines) {
+		t.Errorf("Expected %d events, got %d", len(lines), len(events))
+	}
+
+	// The events list the skipped lines as well
+	var eventDiagnostics []types.Diagnostic
+	for _, event := range events {
+		if event.Kind == types.EventDiagnostic {
+			eventDiagnostics = append(eventDiagnostics, *event.Diagnostic)
+		}
+	}
+	if !reflect.DeepEqual(eventDiagnostics, expected) {
+		t.Errorf("Expected event diagnostics %+v, got %+v", expected, eventDiagnostics)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	lines := []string{
+		"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+		" 20:50 ShutdownGame:",
+		" 20:50 InitGame: \\g_gametype\\4\\mapname\\q3ctf1",
+		" 20:51 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+		" 20:52 red:8  blue:",
+	}
+
+	tests := []struct {
+		description string
+		run         func() error
+	}{
+		{
+			description: "Parse",
+			run: func() error {
+				_, err := p.Parse(lines)
+				return err
+			},
+		},
+		{
+			description: "ParseConcurrent",
+			run: func() error {
+				return p.ParseConcurrent(&sliceScanner{lines: lines}, 2, func(key string, game types.Game) error {
+					return nil
+				})
+			},
+		},
+		{
+			description: "Events",
+			run: func() error {
+				_, err := p.Events(lines)
+				return err
+			},
+		},
+	}
+
+	expected := ParseError{Line: 5, Game: "game_2", Kind: types.EventTeamScore, Raw: lines[4]}
+	for _, test := range tests {
+		err := test.run()
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: Expected a ParseError, got %v", test.description, err)
+			continue
+		}
+		if parseErr.Line != expected.Line || parseErr.Game != expected.Game || parseErr.Kind != expected.Kind || parseErr.Raw != expected.Raw {
+			t.Errorf("%s: Expected %+v, got %+v", test.description, expected, *parseErr)
+		}
+		if errors.Unwrap(parseErr) == nil {
+			t.Errorf("%s: Expected a cause, got nil", test.description)
+		}
+		if err.Error() != "line 5 (game_2): TeamScore: could not parse team score: 8  blue:" {
+			t.Errorf("%s: Unexpected message: %v", test.description, err)
+		}
+	}
+}
+
+// filesScanner reads the lines of several files one after the other, counting
+// the lines of each file from 1 as reader.Open does.
+type filesScanner struct {
+	filePaths []string
+	files     [][]string
+	file      int
+	line      int
+}
+
+func (s *filesScanner) Scan() bool {
+	for s.file < len(s.files) {
+		if s.line < len(s.files[s.file]) {
+			s.line++
+			return true
+		}
+		s.file++
+		s.line = 0
+	}
+	return false
+}
+
+func (s *filesScanner) Text() string {
+	return s.files[s.file][s.line-1]
+}
+
+func (s *filesScanner) Err() error {
+	return nil
+}
+
+func (s *filesScanner) Position() (string, int) {
+	return s.filePaths[s.file], s.line
+}
+
+func TestParseErrorFiles(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	files := [][]string{
+		{
+			"  0:00 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+			" 20:50 ShutdownGame:",
+		},
+		{
+			" 20:50 InitGame: \\g_gametype\\0\\mapname\\q3dm17",
+			" 20:51 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0",
+			" 20:52 Kill: 2 3 7: Isgalamido killed Mocinha by",
+		},
+	}
+	newScanner := func() *filesScanner {
+		return &filesScanner{filePaths: []string{"a.log", "b.log"}, files: files}
+	}
+
+	tests := []struct {
+		description string
+		run         func() error
+	}{
+		{
+			description: "ParseStream",
+			run: func() error {
+				return p.ParseStream(newScanner(), func(key string, game types.Game) error {
+					return nil
+				})
+			},
+		},
+		{
+			description: "EmitStream",
+			run: func() error {
+				return p.EmitStream(newScanner(), func(event types.Event) error {
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.run()
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: Expected a ParseError, got %v", test.description, err)
+			continue
+		}
+		if parseErr.File != "b.log" || parseErr.Line != 3 {
+			t.Errorf("%s: Expected line 3 of b.log, got %+v", test.description, *parseErr)
+		}
+		if err.Error() != "b.log:3 (game_2): Kill: could not parse means: 2 3 7: Isgalamido killed Mocinha by" {
+			t.Errorf("%s: Unexpected message: %v", test.description, err)
+		}
+	}
+
+	lenient := NewParser(zap.NewNop())
+	lenient.SetLenient(true)
+	games := types.GameMap{}
+	err := lenient.ParseStream(newScanner(), func(key string, game types.Game) error {
+		games[key] = game
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error in lenient mode: %v", err)
+	}
+	expected := []types.Diagnostic{
+		{File: "b.log", Line: 3, Game: "game_2", Raw: files[1][2], Reason: "could not parse means: 2 3 7: Isgalamido killed Mocinha by"},
+	}
+	if !reflect.DeepEqual(games["game_2"].Diagnostics, expected) {
+		t.Errorf("Expected diagnostics %+v, got %+v", expected, games["game_2"].Diagnostics)
+	}
+}
+
+func TestRankings(t *testing.T) {
+	p := NewParser(nil)
+
+	games := types.GameMap{
+		"game_1": {
+			PlayerList: []types.Player{
+				{CurrentUsername: "Isgalamido", Kills: 5, Frags: 6, Deaths: 2},
+				{CurrentUsername: "Zeh", Kills: 2, Frags: 2, Deaths: 4},
+			},
+			Scoreboard: []types.Score{{Name: "Isgalamido", Score: 6}, {Name: "Zeh", Score: 2}},
+		},
+		"game_2": {
+			PlayerList: []types.Player{
+				{CurrentUsername: "Isgalamido", Kills: 1, Frags: 1, Deaths: 3, Score: 1},
+				{CurrentUsername: "Zeh", Kills: 1, Frags: 1, Deaths: 1, Score: 1},
+			},
+		},
+		"game_3": {
+			PlayerList: []types.Player{
+				{CurrentUsername: "Zeh", Kills: 4, Frags: 4, Deaths: 1, Team: teamRed},
+				{CurrentUsername: "Mocinha", Kills: 3, Frags: 3, Deaths: 0, Team: teamRed},
+				{CurrentUsername: "Isgalamido", Kills: 0, Frags: 0, Deaths: 7, Team: teamBlue},
+			},
+			Teams: &types.Teams{
+				Red:    types.Team{Players: []string{"Zeh", "Mocinha"}},
+				Blue:   types.Team{Players: []string{"Isgalamido"}},
+				Winner: teamRed,
+			},
+		},
+		"game_4": {
+			PlayerList: []types.Player{
+				{CurrentUsername: "Mocinha", Kills: 4, Frags: 4, Deaths: 0, Score: 4},
+			},
+			Incomplete: true,
+		},
+	}
+
+	// Mocinha and Zeh have the same kills, so the better K/D ratio comes first.
+	// Game 2 ended in a tie and game 4 was cut off, so they have no winner.
+	expected := []types.Ranking{
+		{Player: "Mocinha", GamesPlayed: 2, Wins: 1, Kills: 7, Frags: 7, Deaths: 0, KDRatio: 7},
+		{Player: "Zeh", GamesPlayed: 3, Wins: 1, Kills: 7, Frags: 7, Deaths: 6, KDRatio: 1.17},
+		{Player: "Isgalamido", GamesPlayed: 3, Wins: 1, Kills: 6, Frags: 7, Deaths: 12, KDRatio: 0.58},
+	}
+
+	rankings := p.Rankings(games)
+	if !reflect.DeepEqual(rankings, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, rankings)
+	}
+
+	if len(p.Rankings(types.GameMap{})) != 0 {
+		t.Errorf("Expected no rankings without games")
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		description string
+		line        string
+		expectedOK  bool
+		expected    token
+	}{
+		{
+			description: "kill line",
+			line:        " 20:54 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+			expectedOK:  true,
+			expected:    token{time: 1254, kind: "Kill", args: "1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT"},
+		},
+		{
+			description: "three digit minutes",
+			line:        "981:21 say: Oootsimo: team red",
+			expectedOK:  true,
+			expected:    token{time: 58881, kind: "say", args: "Oootsimo: team red"},
+		},
+		{
+			description: "no arguments",
+			line:        "\t 1:47 ShutdownGame:",
+			expectedOK:  true,
+			expected:    token{time: 107, kind: "ShutdownGame", args: ""},
+		},
+		{
+			description: "team score",
+			line:        " 10:12 red:8  blue:6",
+			expectedOK:  true,
+			expected:    token{time: 612, kind: "red", args: "8  blue:6"},
+		},
+		{
+			description: "separator line",
+			line:        "  0:00 ------------------------------------------------------------",
+		},
+		{
+			description: "missing seconds",
+			line:        " 20: Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+		},
+		{
+			description: "missing kind colon",
+			line:        " 20:54 Kill 1022 2 22",
+		},
+		{
+			description: "empty line",
+			line:        "",
+		},
+	}
+
+	for _, test := range tests {
+		result, ok := tokenize(test.line)
+		if ok != test.expectedOK {
+			t.Errorf("%s: Expected ok %v, got %v", test.description, test.expectedOK, ok)
+		}
+		if result != test.expected {
+			t.Errorf("%s: Expected %+v, got %+v", test.description, test.expected, result)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	p := NewParser(zap.NewNop())
+
+	tests := []struct {
+		description string
+		line        string
+	}{
+		{
+			description: "kill without ids",
+			line:        " 20:54 Kill: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+		},
+		{
+			description: "kill without means",
+			line:        " 20:54 Kill: 1022 2 22: <world> killed Isgalamido",
+		},
+		{
+			description: "userinfo without id",
+			line:        " 20:34 ClientUserinfoChanged: n\\Isgalamido\\t\\0",
+		},
+		{
+			description: "score without client",
+			line:        " 20:37 score: 20  ping: 4",
+		},
+		{
+			description: "team score without blue",
+			line:        " 10:12 red:8",
+		},
+	}
+
+	for _, test := range tests {
+		_, _, err := p.parseEvent(1, test.line)
+		if err == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+	}
+}
+
+var benchmarkGameLines = []string{
+	"  0:00 InitGame: \\sv_floodProtect\\1\\sv_hostname\\Code Miner Server\\g_gametype\\0\\fraglimit\\20\\timelimit\\15\\capturelimit\\8\\version\\ioq3 1.36 linux-x86_64 Apr 12 2009\\protocol\\68\\mapname\\q3dm17",
+	"  0:25 ClientConnect: 2",
+	"  0:25 ClientUserinfoChanged: 2 n\\Dono da Bola\\t\\0\\model\\sarge\\hmodel\\sarge\\g_redteam\\\\g_blueteam\\\\c1\\4\\c2\\5\\hc\\95\\w\\0\\l\\0\\tt\\0\\tl\\0",
+	"  0:27 ClientBegin: 2",
+	"  0:29 ClientConnect: 3",
+	"  0:29 ClientUserinfoChanged: 3 n\\Isgalamido\\t\\0\\model\\uriel/zael\\hmodel\\uriel/zael\\g_redteam\\\\g_blueteam\\\\c1\\5\\c2\\5\\hc\\100\\w\\0\\l\\0\\tt\\0\\tl\\0",
+	"  0:31 ClientBegin: 3",
+	"  0:32 Item: 3 weapon_rocketlauncher",
+	"  0:33 Item: 2 item_armor_body",
+	"  0:40 Kill: 3 2 7: Isgalamido killed Dono da Bola by MOD_ROCKET_SPLASH",
+	"  0:45 Kill: 1022 3 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+	"  0:50 Kill: 2 3 10: Dono da Bola killed Isgalamido by MOD_RAILGUN",
+	"  0:55 Kill: 2 2 7: Dono da Bola killed Dono da Bola by MOD_ROCKET_SPLASH",
+	"  1:00 say: Isgalamido: gg",
+	"  1:05 Exit: Fraglimit hit.",
+	"  1:05 score: 0  ping: 4  client: 2 Dono da Bola",
+	"  1:05 score: 1  ping: 8  client: 3 Isgalamido",
+	"  1:05 ShutdownGame:",
+	"  1:05 ------------------------------------------------------------",
+}
+
+// syntheticScanner generates a log of the given number of games without holding it in memory.
+type syntheticScanner struct {
+	games int
+	line  int
+}
+
+func (s *syntheticScanner) Scan() bool {
+	s.line++
+	return s.line <= s.games*len(benchmarkGameLines)
+}
+
+func (s *syntheticScanner) Text() string {
+	return benchmarkGameLines[(s.line-1)%len(benchmarkGameLines)]
+}
+
+func (s *syntheticScanner) Err() error {
+	return nil
+}
+
+func peakHeap(peak *uint64) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapInuse > *peak {
+		*peak = stats.HeapInuse
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	p := NewParser(zap.NewNop())
+
+	for _, games := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("games=%d", games), func(b *testing.B) {
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				scanner := &syntheticScanner{games: games}
+				arrayLines := []string{}
+				for scanner.Scan() {
+					arrayLines = append(arrayLines, strings.Clone(scanner.Text()))
+				}
+				peakHeap(&peak)
+
+				_, err := p.Parse(arrayLines)
+				if err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+				peakHeap(&peak)
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MiB")
+		})
+	}
+}
+
+func BenchmarkParseStream(b *testing.B) {
+	p := NewParser(zap.NewNop())
+
+	for _, games := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("games=%d", games), func(b *testing.B) {
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				count := 0
+				err := p.ParseStream(&syntheticScanner{games: games}, func(key string, game types.Game) error {
+					count++
+					if count%100 == 0 {
+						peakHeap(&peak)
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MiB")
+		})
+	}
+}
+
+// regexpEventPattern is how lines were classified before the tokenizer, kept
+// as a baseline for BenchmarkClassify.
+var regexpEventPattern = regexp.MustCompile(`^\s*(\d+):(\d+) ([A-Za-z]+):(.*)$`)
+
+func BenchmarkClassify(b *testing.B) {
+	b.Run("tokenizer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range benchmarkGameLines {
+				tokenize(line)
+			}
+		}
+	})
+
+	b.Run("regexp", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, line := range benchmarkGameLines {
+				regexpEventPattern.FindStringSubmatch(line)
+			}
+		}
+	})
+}
+
+func BenchmarkEmitStream(b *testing.B) {
+	p := NewParser(zap.NewNop())
+
+	gameBytes := 0
+	for _, line := range benchmarkGameLines {
+		gameBytes += len(line) + 1
+	}
+
+	games := 10000
+	b.SetBytes(int64(games * gameBytes))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		err := p.EmitStream(&syntheticScanner{games: games}, func(event types.Event) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	b.ReportMetric(float64(b.N*games*len(benchmarkGameLines))/b.Elapsed().Seconds(), "lines/s")
+}
+
+func BenchmarkParseConcurrent(b *testing.B) {
+	p := NewParser(zap.NewNop())
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				err := p.ParseConcurrent(&syntheticScanner{games: 10000}, workers, func(key string, game types.Game) error {
+					return nil
+				})
+				if err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"sort"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+)
+
+// Rankings sums up the player stats of all games, players being matched by
+// name. They are sorted by kills, then by K/D ratio, wins and name.
+func (p *Parser) Rankings(games types.GameMap) []types.Ranking {
+	rankings := []types.Ranking{}
+	index := make(map[string]int)
+
+	for _, key := range games.Keys() {
+		game := games[key]
+		winners := p.winners(game)
+
+		for _, player := range game.PlayerList {
+			i, ok := index[player.CurrentUsername]
+			if !ok {
+				i = len(rankings)
+				index[player.CurrentUsername] = i
+				rankings = append(rankings, types.Ranking{Player: player.CurrentUsername})
+			}
+
+			rankings[i].GamesPlayed++
+			rankings[i].Kills += player.Kills
+			rankings[i].Frags += player.Frags
+			rankings[i].Deaths += player.Deaths
+			if winners[player.CurrentUsername] {
+				rankings[i].Wins++
+			}
+		}
+	}
+
+	for i := range rankings {
+		rankings[i].KDRatio = p.kdRatio(rankings[i].Frags, rankings[i].Deaths)
+	}
+
+	sort.SliceStable(rankings, func(i, j int) bool {
+		a, b := rankings[i], rankings[j]
+		if a.Kills != b.Kills {
+			return a.Kills > b.Kills
+		}
+		if a.KDRatio != b.KDRatio {
+			return a.KDRatio > b.KDRatio
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Player < b.Player
+	})
+
+	return rankings
+}
+
+// winners returns the players who won a finished game: the winning team in
+// team games, or else the only player with the best score. Games that were cut
+// off or ended in a tie have no winners.
+func (p *Parser) winners(game types.Game) map[string]bool {
+	winners := make(map[string]bool)
+	if game.Incomplete {
+		return winners
+	}
+
+	if game.Teams != nil {
+		var players []string
+		switch game.Teams.Winner {
+		case teamRed:
+			players = game.Teams.Red.Players
+		case teamBlue:
+			players = game.Teams.Blue.Players
+		}
+		for _, player := range players {
+			winners[player] = true
+		}
+		return winners
+	}
+
+	// The server scoreboard is authoritative, our own score is the fallback
+	scores := make(map[string]int)
+	if len(game.Scoreboard) > 0 {
+		for _, score := range game.Scoreboard {
+			scores[score.Name] = score.Score
+		}
+	} else {
+		for _, player := range game.PlayerList {
+			scores[player.CurrentUsername] = player.Score
+		}
+	}
+
+	best := ""
+	tie := false
+	for name, score := range scores {
+		switch {
+		case best == "" || score > scores[best]:
+			best = name
+			tie = false
+		case score == scores[best]:
+			tie = true
+		}
+	}
+	if best != "" && !tie {
+		winners[best] = true
+	}
+	return winners
+}
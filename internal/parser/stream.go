@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+// LineScanner is a source of log lines read one at a time. It is satisfied by
+// *bufio.Scanner and by the lines returned by reader.Open.
+type LineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// FileScanner is a LineScanner that reads one or more files and knows where its
+// current line was read, such as the lines returned by reader.Open. Errors then
+// point at the file and line within it instead of counting lines across files.
+type FileScanner interface {
+	LineScanner
+	Position() (filePath string, line int)
+}
+
+// position is where a line was read. The file is empty for scanners that are
+// not a FileScanner, with lines counted from the start of the scanner.
+type position struct {
+	file string
+	line int
+}
+
+func (p *Parser) position(scanner LineScanner, lineNumber int) position {
+	if files, ok := scanner.(FileScanner); ok {
+		filePath, line := files.Position()
+		return position{file: filePath, line: line}
+	}
+	return position{line: lineNumber}
+}
+
+// GameHandler receives every game as soon as it is complete, in log order.
+type GameHandler func(key string, game types.Game) error
+
+// ParseStream parses the lines from scanner game by game, handing each game to
+// handle as soon as its ShutdownGame line is read, or once the next game starts
+// for games that were cut off. Only the game being parsed is kept in memory.
+func (p *Parser) ParseStream(scanner LineScanner, handle GameHandler) error {
+	err := p.splitGames(scanner, func(chunk gameChunk) error {
+		game, err := p.parseGame(chunk)
+		if err != nil {
+			return err
+		}
+		return handle(p.formatGameNumber(chunk.gameNumber), game)
+	})
+	if err != nil {
+		p.logger.Error("error processing new game", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// gameChunk holds the raw lines of a single game and where each was read.
+// Index counts the games in the order they were read.
+type gameChunk struct {
+	index      int
+	gameNumber int
+	lines      []string
+	positions  []position
+}
+
+// splitGames groups the lines from scanner into games, only classifying each
+// line, and hands every game to handle as soon as it ends. Lines before the
+// first InitGame are kept as game 0, and lines after a ShutdownGame are dropped
+// until the next InitGame. Live logs drop both game 0 and the game left open.
+func (p *Parser) splitGames(scanner LineScanner, handle func(chunk gameChunk) error) error {
+	lineNumber := 0
+	chunk := gameChunk{}
+	gameOpen := !p.live
+
+	index := 0
+	emit := func() error {
+		chunk.index = index
+		index++
+		return handle(chunk)
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		token, ok := tokenize(line)
+
+		if ok && token.kind == string(types.EventInitGame) {
+			if gameOpen && len(chunk.lines) > 0 {
+				err := emit()
+				if err != nil {
+					return err
+				}
+			}
+			chunk = gameChunk{gameNumber: chunk.gameNumber + 1}
+			gameOpen = true
+		}
+		if !gameOpen {
+			continue
+		}
+
+		chunk.lines = append(chunk.lines, line)
+		chunk.positions = append(chunk.positions, p.position(scanner, lineNumber))
+		if ok && token.kind == string(types.EventShutdownGame) {
+			gameOpen = false
+			err := emit()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		p.logger.Error("error scanning lines", zap.Int("line", lineNumber), zap.Error(err))
+		return err
+	}
+
+	if gameOpen && len(chunk.lines) > 0 && !p.live {
+		return emit()
+	}
+	return nil
+}
+
+// parseGame parses the lines of a single game. It only reads the parser, so
+// games can be parsed concurrently.
+func (p *Parser) parseGame(chunk gameChunk) (types.Game, error) {
+	game := p.newGame()
+	for i, line := range chunk.lines {
+		event, ok, err := p.readEvent(chunk.positions[i], chunk.gameNumber, line)
+		if err != nil {
+			return game, err
+		}
+		if !ok {
+			continue
+		}
+		event.Game = chunk.gameNumber
+
+		game, err = p.processEvent(event, game)
+		if err != nil {
+			return game, err
+		}
+	}
+	return p.finishGame(game), nil
+}
+
+type sliceScanner struct {
+	lines []string
+	index int
+}
+
+func (s *sliceScanner) Scan() bool {
+	if s.index >= len(s.lines) {
+		return false
+	}
+	s.index++
+	return true
+}
+
+func (s *sliceScanner) Text() string {
+	return s.lines[s.index-1]
+}
+
+func (s *sliceScanner) Err() error {
+	return nil
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+)
+
+// token is a log line split into its timestamp, event kind and the arguments
+// after the kind. Kind and args share the memory of the line.
+type token struct {
+	time types.Timestamp
+	kind string
+	args string
+}
+
+// tokenize reads the timestamp and the event kind of a line in a single pass,
+// without allocating. Lines that carry no event, such as the separator lines,
+// are reported as not ok.
+func tokenize(line string) (token, bool) {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+
+	minutes, i := readNumber(line, i)
+	if minutes < 0 || i >= len(line) || line[i] != ':' {
+		return token{}, false
+	}
+	seconds, i := readNumber(line, i+1)
+	if seconds < 0 || i >= len(line) || line[i] != ' ' {
+		return token{}, false
+	}
+
+	start := i + 1
+	i = start
+	for i < len(line) && isLetter(line[i]) {
+		i++
+	}
+	if i == start || i >= len(line) || line[i] != ':' {
+		return token{}, false
+	}
+
+	return token{
+		time: types.Timestamp(minutes*60 + seconds),
+		kind: line[start:i],
+		args: strings.TrimSpace(line[i+1:]),
+	}, true
+}
+
+// readNumber reads the digits of line starting at i. It returns -1 when there
+// are none, along with the index of the first byte after the digits.
+func readNumber(line string, i int) (int, int) {
+	start := i
+	number := 0
+	for i < len(line) && isDigit(line[i]) {
+		number = number*10 + int(line[i]-'0')
+		i++
+	}
+	if i == start {
+		return -1, i
+	}
+	return number, i
+}
+
+// cutNumber cuts the digits at the start of s, which must be followed by sep.
+func cutNumber(s string, sep byte) (number, rest string, ok bool) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i == 0 || i >= len(s) || s[i] != sep {
+		return "", s, false
+	}
+	return s[:i], s[i+1:], true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseKill parses the arguments of a Kill line, such as
+// 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT.
+func parseKill(args string) (types.Kill, error) {
+	killerID, rest, killerOK := cutNumber(args, ' ')
+	victimID, rest, victimOK := cutNumber(rest, ' ')
+	meansID, rest, meansOK := cutNumber(rest, ':')
+	if !killerOK || !victimOK || !meansOK {
+		return types.Kill{}, fmt.Errorf("could not parse line: %s", args)
+	}
+
+	// Means names never contain spaces, so the last " by " ends the victim name
+	byIndex := strings.LastIndex(rest, " by ")
+	if byIndex < 0 {
+		return types.Kill{}, fmt.Errorf("could not parse means: %s", args)
+	}
+	killer, killed, ok := strings.Cut(rest[:byIndex], " killed ")
+	if !ok {
+		return types.Kill{}, fmt.Errorf("could not parse killer: %s", args)
+	}
+
+	return types.Kill{
+		KillerID: killerID,
+		VictimID: victimID,
+		MeansID:  meansID,
+		Killer:   strings.TrimSpace(killer),
+		Victim:   strings.TrimSpace(killed),
+		Means:    strings.TrimSpace(rest[byIndex+len(" by "):]),
+	}, nil
+}
+
+// parseUserDetails parses the client id and the name from the arguments of a
+// ClientUserinfoChanged line, such as 2 n\Isgalamido\t\0.
+func parseUserDetails(args string) (userID, username, info string, err error) {
+	userID, info, ok := cutNumber(args, ' ')
+	if !ok {
+		return "", "", "", fmt.Errorf("could not parse userId: %s", args)
+	}
+
+	_, rest, ok := strings.Cut(info, "\\")
+	if !ok {
+		return "", "", "", fmt.Errorf("could not parse line: %s", args)
+	}
+	username, _, _ = strings.Cut(rest, "\\")
+
+	return userID, username, info, nil
+}
+
+// parseScore parses the arguments of a score line, such as 20  ping: 4  client: 4 Zeh.
+func parseScore(args string) (types.Score, error) {
+	scoreText, rest, scoreOK := strings.Cut(args, " ping:")
+	pingText, rest, pingOK := strings.Cut(rest, " client:")
+	clientID, name, clientOK := cutNumber(strings.TrimLeft(rest, " "), ' ')
+	if !scoreOK || !pingOK || !clientOK {
+		return types.Score{}, fmt.Errorf("could not parse score: %s", args)
+	}
+
+	score, err := strconv.Atoi(strings.TrimSpace(scoreText))
+	if err != nil {
+		return types.Score{}, fmt.Errorf("could not parse score: %s", args)
+	}
+	ping, err := strconv.Atoi(strings.TrimSpace(pingText))
+	if err != nil || ping < 0 {
+		return types.Score{}, fmt.Errorf("could not parse score: %s", args)
+	}
+
+	return types.Score{
+		ClientID: clientID,
+		Name:     strings.TrimSpace(name),
+		Score:    score,
+		Ping:     ping,
+	}, nil
+}
+
+// parseTeamScore parses the arguments of a team score line, such as 8  blue:6,
+// which follow the red: kind.
+func parseTeamScore(args string) (types.TeamScore, error) {
+	redText, blueText, ok := strings.Cut(args, " blue:")
+	if !ok {
+		return types.TeamScore{}, fmt.Errorf("could not parse team score: %s", args)
+	}
+
+	red, redErr := strconv.Atoi(strings.TrimSpace(redText))
+	blue, blueErr := strconv.Atoi(strings.TrimSpace(blueText))
+	if redErr != nil || blueErr != nil {
+		return types.TeamScore{}, fmt.Errorf("could not parse team score: %s", args)
+	}
+	return types.TeamScore{Red: red, Blue: blue}, nil
+}
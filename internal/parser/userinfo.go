@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+)
+
+// DecodeUserinfo decodes the info string of a ClientUserinfoChanged line, such as
+// n\Isgalamido\t\0\model\xian/default. Keys it does not know, and values that
+// cannot be decoded, are kept in Unknown. Only a missing name is an error.
+func DecodeUserinfo(info string) (types.Userinfo, error) {
+	var userinfo types.Userinfo
+	hasName := false
+
+	// The pairs are cut one at a time instead of splitting the whole string
+	rest := strings.TrimPrefix(info, "\\")
+	for more := rest != ""; more; {
+		var key, value string
+		key, rest, more = strings.Cut(rest, "\\")
+		if more {
+			value, rest, more = strings.Cut(rest, "\\")
+		}
+
+		var err error
+		switch key {
+		case "n":
+			userinfo.Name = value
+			hasName = true
+		case "t":
+			userinfo.Team, err = decodeTeam(value)
+		case "model":
+			userinfo.Model = value
+		case "hmodel":
+			userinfo.HeadModel = value
+		case "g_redteam":
+			userinfo.RedTeam = value
+		case "g_blueteam":
+			userinfo.BlueTeam = value
+		case "c1":
+			userinfo.Color1 = value
+		case "c2":
+			userinfo.Color2 = value
+		case "hc":
+			userinfo.Handicap, err = strconv.Atoi(value)
+		case "w":
+			userinfo.Wins, err = strconv.Atoi(value)
+		case "l":
+			userinfo.Losses, err = strconv.Atoi(value)
+		case "tt":
+			userinfo.TeamTask, err = strconv.Atoi(value)
+		case "tl":
+			userinfo.TeamLeader = value == "1"
+		default:
+			setUnknown(&userinfo, key, value)
+		}
+		if err != nil {
+			setUnknown(&userinfo, key, value)
+		}
+	}
+
+	if !hasName {
+		return userinfo, fmt.Errorf("could not parse userinfo name: %s", info)
+	}
+	return userinfo, nil
+}
+
+func setUnknown(userinfo *types.Userinfo, key, value string) {
+	if userinfo.Unknown == nil {
+		userinfo.Unknown = make(map[string]string)
+	}
+	userinfo.Unknown[key] = value
+}
+
+func decodeTeam(value string) (string, error) {
+	switch value {
+	case "0":
+		return teamFree, nil
+	case "1":
+		return teamRed, nil
+	case "2":
+		return teamBlue, nil
+	case "3":
+		return teamSpectator, nil
+	}
+	return "", fmt.Errorf("unknown team %s", value)
+}
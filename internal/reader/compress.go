@@ -0,0 +1,76 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ErrTooLarge is returned when an input is larger than the size set with
+// Reader.SetMaxSize once decompressed.
+var ErrTooLarge = errors.New("input is too large")
+
+// decompress wraps input in a decompressor when the file is gzip or zstd
+// compressed, going by its extension or else by its first bytes.
+func decompress(filePath string, input io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(input)
+	magic, _ := buffered.Peek(len(zstdMagic))
+	extension := strings.ToLower(filepath.Ext(filePath))
+
+	switch {
+	case extension == ".gz" || bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case extension == ".zst" || extension == ".zstd" || bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+// limitedInput fails reading input once more than limit bytes were read from
+// it, so that a small compressed file cannot expand without bounds.
+type limitedInput struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+	err       error
+}
+
+func limitInput(input io.ReadCloser, limit int64) *limitedInput {
+	return &limitedInput{ReadCloser: input, limit: limit, remaining: limit}
+}
+
+func (l *limitedInput) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	if int64(n) > l.remaining {
+		l.err = fmt.Errorf("%w: more than %d bytes once decompressed", ErrTooLarge, l.limit)
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, l.err
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,193 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var followInterval = 500 * time.Millisecond
+
+// Follow tails a growing log file like tail -F. It waits for the file to exist,
+// reopens it when it is rotated and starts over when it is truncated. The file is
+// read from the start, and Backlog tells the lines that were already in it apart.
+// Scan blocks until a new line is written and stops once ctx is done.
+func (r *Reader) Follow(ctx context.Context, filePath string) *Follower {
+	return &Follower{
+		logger:   r.logger,
+		ctx:      ctx,
+		filePath: filePath,
+	}
+}
+
+type Follower struct {
+	logger   *zap.Logger
+	ctx      context.Context
+	filePath string
+	opened   bool
+	rotated  bool
+	backlog  int64
+	file     *os.File
+	info     os.FileInfo
+	reader   *bufio.Reader
+	offset   int64
+	partial  string
+	line     string
+	lineNum  int
+	err      error
+}
+
+func (f *Follower) Scan() bool {
+	for f.err == nil && f.ctx.Err() == nil {
+		if f.file == nil && !f.openFile() {
+			f.wait()
+			continue
+		}
+
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		f.partial += chunk
+		if err == nil {
+			f.line = strings.TrimRight(f.partial, "\r\n")
+			f.partial = ""
+			f.lineNum++
+			return true
+		}
+		if err != io.EOF {
+			f.logger.Error("error reading followed file", zap.Error(err))
+			f.err = err
+			return false
+		}
+
+		if f.rotated {
+			// The rotated file was read to its end, hand out a last line left
+			// without a newline before moving on to the new file
+			f.Close()
+			if f.partial != "" {
+				f.line = strings.TrimRight(f.partial, "\r\n")
+				f.partial = ""
+				f.lineNum++
+				return true
+			}
+			continue
+		}
+
+		// At the end of the file, check whether it was rotated or truncated
+		// before waiting for more lines
+		f.checkFile()
+		if f.file != nil && !f.rotated {
+			f.wait()
+		}
+	}
+	return false
+}
+
+func (f *Follower) Text() string {
+	return f.line
+}
+
+// Backlog reports whether the current line was already in the file when it was
+// first opened, as opposed to written while following it.
+func (f *Follower) Backlog() bool {
+	return f.offset <= f.backlog
+}
+
+// Position returns the followed file and the number of the current line in it,
+// counted from the start of the file currently open.
+func (f *Follower) Position() (filePath string, line int) {
+	return f.filePath, f.lineNum
+}
+
+func (f *Follower) Err() error {
+	return f.err
+}
+
+func (f *Follower) Close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *Follower) openFile() bool {
+	file, err := os.Open(f.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		// A file created after following started has no backlog
+		f.opened = true
+		return false
+	}
+	if err != nil {
+		f.logger.Error("error opening followed file", zap.Error(err))
+		f.err = err
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		f.logger.Error("error checking followed file", zap.Error(err))
+		f.err = err
+		return false
+	}
+
+	// Only the file opened first has a backlog, files opened after a rotation
+	// are entirely new
+	f.backlog = 0
+	if !f.opened {
+		f.backlog = info.Size()
+	}
+	f.opened = true
+	f.rotated = false
+	f.offset = 0
+	f.lineNum = 0
+	f.file = file
+	f.info = info
+	f.reader = bufio.NewReader(file)
+	f.partial = ""
+	return true
+}
+
+func (f *Follower) checkFile() {
+	info, err := os.Stat(f.filePath)
+	if err != nil || !os.SameFile(f.info, info) {
+		// Lines may have been written to the old file since it was last read,
+		// so it is read to its end once more before it is closed
+		f.logger.Info("followed file was rotated", zap.String("file", f.filePath))
+		f.rotated = true
+		return
+	}
+
+	if info.Size() < f.offset {
+		f.logger.Info("followed file was truncated", zap.String("file", f.filePath))
+		_, err = f.file.Seek(0, io.SeekStart)
+		if err != nil {
+			f.logger.Error("error seeking followed file", zap.Error(err))
+			f.err = err
+			return
+		}
+		f.offset = 0
+		f.lineNum = 0
+		f.backlog = 0
+		f.reader.Reset(f.file)
+		f.partial = ""
+	}
+}
+
+func (f *Follower) wait() {
+	timer := time.NewTimer(followInterval)
+	defer timer.Stop()
+
+	select {
+	case <-f.ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,147 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFollow(t *testing.T) {
+	followInterval = 10 * time.Millisecond
+	r := NewReader(zap.NewNop())
+
+	filePath := filepath.Join(t.TempDir(), "games.log")
+	err := os.WriteFile(filePath, []byte("20:00 InitGame: line 0\n"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing test file: %v", err)
+	}
+
+	appendLine := func(line string) error {
+		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = file.WriteString(line)
+		return err
+	}
+
+	tests := []struct {
+		description    string
+		change         func() error
+		expectedLine   string
+		expectedNumber int
+	}{
+		{
+			description:    "appended line",
+			change:         func() error { return appendLine("20:01 Kill: line 1\n") },
+			expectedLine:   "20:01 Kill: line 1",
+			expectedNumber: 2,
+		},
+		{
+			description: "line written in two parts",
+			change: func() error {
+				err := appendLine("20:02 Kill: ")
+				if err != nil {
+					return err
+				}
+				time.Sleep(3 * followInterval)
+				return appendLine("line 2\r\n")
+			},
+			expectedLine:   "20:02 Kill: line 2",
+			expectedNumber: 3,
+		},
+		{
+			description:    "truncated file",
+			change:         func() error { return os.WriteFile(filePath, []byte("0:00 line 3\n"), 0644) },
+			expectedLine:   "0:00 line 3",
+			expectedNumber: 1,
+		},
+		{
+			description: "rotated file",
+			change: func() error {
+				err := os.Rename(filePath, filePath+".1")
+				if err != nil {
+					return err
+				}
+				time.Sleep(3 * followInterval)
+				return os.WriteFile(filePath, []byte("0:00 line 4\n"), 0644)
+			},
+			expectedLine:   "0:00 line 4",
+			expectedNumber: 1,
+		},
+		{
+			description: "line left unfinished by a rotation",
+			change: func() error {
+				err := appendLine("0:00 line 5")
+				if err != nil {
+					return err
+				}
+				time.Sleep(3 * followInterval)
+				err = os.Rename(filePath, filePath+".2")
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(filePath, []byte("0:00 line 6\n"), 0644)
+			},
+			expectedLine:   "0:00 line 5",
+			expectedNumber: 2,
+		},
+		{
+			description:    "new file after a rotation",
+			change:         func() error { return nil },
+			expectedLine:   "0:00 line 6",
+			expectedNumber: 1,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lines := r.Follow(ctx, filePath)
+	defer lines.Close()
+
+	// The lines already in the file are read first, as backlog
+	if !lines.Scan() || lines.Text() != "20:00 InitGame: line 0" {
+		t.Fatalf("Expected the line already in the file, got %q (error: %v)", lines.Text(), lines.Err())
+	}
+	if !lines.Backlog() {
+		t.Errorf("Expected the line already in the file to be backlog")
+	}
+
+	for _, test := range tests {
+		go func(change func() error) {
+			// Give the follower time to reach the end of the file first
+			time.Sleep(3 * followInterval)
+			err := change()
+			if err != nil {
+				t.Errorf("Error changing test file: %v", err)
+			}
+		}(test.change)
+
+		if !lines.Scan() {
+			t.Fatalf("%s: Expected line %q, got none (error: %v)", test.description, test.expectedLine, lines.Err())
+		}
+		if lines.Text() != test.expectedLine {
+			t.Errorf("%s: Expected line %q, got %q", test.description, test.expectedLine, lines.Text())
+		}
+		if lines.Backlog() {
+			t.Errorf("%s: Expected a new line, got backlog", test.description)
+		}
+		if _, number := lines.Position(); number != test.expectedNumber {
+			t.Errorf("%s: Expected line number %d, got %d", test.description, test.expectedNumber, number)
+		}
+	}
+
+	cancel()
+	if lines.Scan() {
+		t.Errorf("Expected no lines after cancel, got %q", lines.Text())
+	}
+	if lines.Err() != nil {
+		t.Errorf("Unexpected error: %v", lines.Err())
+	}
+}
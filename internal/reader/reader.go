@@ -1,14 +1,21 @@
 package reader
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+	"path/filepath"
+	"sort"
 
 	"go.uber.org/zap"
 )
 
+const maxLineSize = 1024 * 1024
+
 type Reader struct {
-	logger *zap.Logger
+	logger  *zap.Logger
+	maxSize int64
 }
 
 func NewReader(logger *zap.Logger) Reader {
@@ -18,13 +25,194 @@ func NewReader(logger *zap.Logger) Reader {
 	return reader
 }
 
+// SetMaxSize limits the size of every input once decompressed, in bytes. Lines
+// past the limit fail with ErrTooLarge. Inputs are not limited by default.
+func (r *Reader) SetMaxSize(maxSize int64) {
+	r.maxSize = maxSize
+}
+
+// Read returns all the lines of filePath at once, decompressing it when it is
+// compressed. Prefer Open, which streams the lines instead.
 func (r *Reader) Read(filePath string) ([]string, error) {
-	content, err := os.ReadFile(filePath)
+	lines := r.Open(filePath)
+	defer lines.Close()
+
+	arrayLines := []string{}
+	for lines.Scan() {
+		arrayLines = append(arrayLines, lines.Text())
+	}
+	return arrayLines, lines.Err()
+}
+
+// Expand resolves the glob patterns among filePaths and returns the files in
+// the order they were given, with the matches of each pattern sorted by name,
+// so that dated archives are read in the order they were played. Files given
+// more than once are only read the first time.
+func (r *Reader) Expand(filePaths ...string) ([]string, error) {
+	paths := []string{}
+	seen := make(map[string]bool)
+	for _, pattern := range filePaths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			r.logger.Error("error expanding input pattern", zap.Error(err))
+			return nil, err
+		}
+		if len(matches) == 0 {
+			// Plain paths are kept so that a missing file is reported below
+			matches = []string{pattern}
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+
+			info, err := os.Stat(match)
+			if err != nil {
+				r.logger.Error("error reading input file", zap.Error(err))
+				return nil, err
+			}
+			if info.IsDir() {
+				return nil, fmt.Errorf("input is a directory: %s", match)
+			}
+			paths = append(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+// Open streams the lines of the given files, one file after the other, without
+// loading them into memory. Compressed files are decompressed on the fly. Files
+// are opened as they are reached, so errors opening them are reported by Err.
+func (r *Reader) Open(filePaths ...string) *Lines {
+	return &Lines{
+		logger:    r.logger,
+		maxSize:   r.maxSize,
+		filePaths: filePaths,
+	}
+}
+
+// Stream streams the lines of input, such as an uploaded log, decompressing it
+// on the fly when it is compressed. Errors reading input are reported by Err.
+func (r *Reader) Stream(input io.Reader) *Lines {
+	lines := &Lines{logger: r.logger, maxSize: r.maxSize}
+	lines.openInput("", input)
+	return lines
+}
+
+type Lines struct {
+	logger    *zap.Logger
+	maxSize   int64
+	filePaths []string
+	filePath  string
+	line      int
+	file      *os.File
+	input     io.ReadCloser
+	limited   *limitedInput
+	scanner   *bufio.Scanner
+	err       error
+}
+
+func (l *Lines) Scan() bool {
+	for l.err == nil {
+		if l.scanner != nil {
+			if l.scanner.Scan() && !l.tooLarge() {
+				l.line++
+				return true
+			}
+			if l.err == nil {
+				l.err = l.scanner.Err()
+			}
+			l.closeFile()
+			continue
+		}
+
+		if len(l.filePaths) == 0 {
+			return false
+		}
+		l.openFile(l.filePaths[0])
+		l.filePaths = l.filePaths[1:]
+	}
+	return false
+}
+
+// tooLarge reports whether the input went over its size limit. The scanner
+// still hands out the lines it read before failing, the last one cut off at the
+// limit, so they are all dropped along with the input.
+func (l *Lines) tooLarge() bool {
+	if l.limited == nil || l.limited.err == nil {
+		return false
+	}
+	l.err = l.limited.err
+	return true
+}
+
+func (l *Lines) Text() string {
+	return l.scanner.Text()
+}
+
+// Position returns the file of the current line, and its line number within
+// that file.
+func (l *Lines) Position() (filePath string, line int) {
+	return l.filePath, l.line
+}
+
+func (l *Lines) Err() error {
+	return l.err
+}
+
+func (l *Lines) Close() error {
+	l.filePaths = nil
+	return l.closeFile()
+}
+
+func (l *Lines) openFile(filePath string) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		r.logger.Error("error reading input file", zap.Error(err))
-		return nil, err
+		l.logger.Error("error opening input file", zap.Error(err))
+		l.err = err
+		return
 	}
-	lines := strings.Split(string(content), "\n")
 
-	return lines, nil
+	l.file = file
+	l.openInput(filePath, file)
+}
+
+func (l *Lines) openInput(filePath string, input io.Reader) {
+	l.filePath = filePath
+	l.line = 0
+
+	decompressed, err := decompress(filePath, input)
+	if err != nil {
+		l.closeFile()
+		l.logger.Error("error decompressing input file", zap.Error(err))
+		l.err = err
+		return
+	}
+
+	if l.maxSize > 0 {
+		l.limited = limitInput(decompressed, l.maxSize)
+		decompressed = l.limited
+	}
+	l.input = decompressed
+	l.scanner = bufio.NewScanner(decompressed)
+	l.scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+}
+
+func (l *Lines) closeFile() error {
+	if l.input != nil {
+		l.input.Close()
+	}
+
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+	}
+	l.file = nil
+	l.input = nil
+	l.limited = nil
+	l.scanner = nil
+	return err
 }
@@ -1,11 +1,42 @@
 package reader
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
 )
 
+func gzipContent(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatalf("Error compressing test file: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func zstdContent(t *testing.T, content string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Error compressing test file: %v", err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll([]byte(content), nil)
+}
+
 func TestRead(t *testing.T) {
 	r := NewReader(nil)
 
@@ -47,3 +78,239 @@ func TestRead(t *testing.T) {
 		}
 	}
 }
+
+func TestOpen(t *testing.T) {
+	r := NewReader(zap.NewNop())
+
+	dir := t.TempDir()
+	firstFile := filepath.Join(dir, "first.log")
+	secondFile := filepath.Join(dir, "second.log")
+	longFile := filepath.Join(dir, "long.log")
+	gzipFile := filepath.Join(dir, "games.log.gz")
+	zstdFile := filepath.Join(dir, "games.log.zst")
+	disguisedFile := filepath.Join(dir, "gzip.log")
+	longLine := "20:00 InitGame: " + strings.Repeat("\\sv_floodProtect\\1", 10000)
+
+	files := map[string][]byte{
+		firstFile:     []byte("20:00 InitGame: line 0\n20:00 InitGame: line 1\n"),
+		secondFile:    []byte("20:00 InitGame: line 2\r\n20:00 InitGame: line 3"),
+		longFile:      []byte(longLine),
+		gzipFile:      gzipContent(t, "20:00 InitGame: line 4\n"),
+		zstdFile:      zstdContent(t, "20:00 InitGame: line 5\n"),
+		disguisedFile: gzipContent(t, "20:00 InitGame: line 6\n"),
+	}
+	for filePath, content := range files {
+		err := os.WriteFile(filePath, content, 0644)
+		if err != nil {
+			t.Fatalf("Error writing test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		description       string
+		filePaths         []string
+		expectedLines     []string
+		expectedPositions []string
+		expectedError     bool
+	}{
+		{
+			description:   "one file",
+			filePaths:     []string{firstFile},
+			expectedLines: []string{"20:00 InitGame: line 0", "20:00 InitGame: line 1"},
+		},
+		{
+			description:       "two files",
+			filePaths:         []string{firstFile, secondFile},
+			expectedLines:     []string{"20:00 InitGame: line 0", "20:00 InitGame: line 1", "20:00 InitGame: line 2", "20:00 InitGame: line 3"},
+			expectedPositions: []string{"first.log:1", "first.log:2", "second.log:1", "second.log:2"},
+		},
+		{
+			description:   "long line",
+			filePaths:     []string{longFile},
+			expectedLines: []string{longLine},
+		},
+		{
+			description:   "compressed files",
+			filePaths:     []string{gzipFile, zstdFile, disguisedFile},
+			expectedLines: []string{"20:00 InitGame: line 4", "20:00 InitGame: line 5", "20:00 InitGame: line 6"},
+		},
+		{
+			description:   "missing file",
+			filePaths:     []string{firstFile, filepath.Join(dir, "missing.log")},
+			expectedLines: []string{"20:00 InitGame: line 0", "20:00 InitGame: line 1"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		lines := r.Open(test.filePaths...)
+
+		var readLines, positions []string
+		for lines.Scan() {
+			readLines = append(readLines, lines.Text())
+			filePath, line := lines.Position()
+			positions = append(positions, fmt.Sprintf("%s:%d", filepath.Base(filePath), line))
+		}
+		lines.Close()
+
+		if !reflect.DeepEqual(readLines, test.expectedLines) {
+			t.Errorf("%s: Expected %v, got %v", test.description, test.expectedLines, readLines)
+		}
+		if test.expectedPositions != nil && !reflect.DeepEqual(positions, test.expectedPositions) {
+			t.Errorf("%s: Expected positions %v, got %v", test.description, test.expectedPositions, positions)
+		}
+		if test.expectedError && lines.Err() == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+		if !test.expectedError && lines.Err() != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, lines.Err())
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	r := NewReader(zap.NewNop())
+
+	tests := []struct {
+		description   string
+		input         []byte
+		maxSize       int64
+		expectedLines []string
+		expectedError bool
+	}{
+		{
+			description:   "plain input",
+			input:         []byte("20:00 InitGame: line 0\r\n20:00 InitGame: line 1\n"),
+			expectedLines: []string{"20:00 InitGame: line 0", "20:00 InitGame: line 1"},
+		},
+		{
+			description:   "gzip input",
+			input:         gzipContent(t, "20:00 InitGame: line 2\n"),
+			expectedLines: []string{"20:00 InitGame: line 2"},
+		},
+		{
+			description:   "zstd input",
+			input:         zstdContent(t, "20:00 InitGame: line 3\n"),
+			expectedLines: []string{"20:00 InitGame: line 3"},
+		},
+		{
+			description:   "input within the size limit once decompressed",
+			input:         gzipContent(t, "20:00 InitGame: line 5\n"),
+			maxSize:       int64(len("20:00 InitGame: line 5\n")),
+			expectedLines: []string{"20:00 InitGame: line 5"},
+		},
+		{
+			description:   "input over the size limit once decompressed",
+			input:         gzipContent(t, "20:00 InitGame: line 6\n"+strings.Repeat("x", 1000)),
+			maxSize:       100,
+			expectedError: true,
+		},
+		{
+			description:   "truncated gzip input",
+			input:         gzipContent(t, "20:00 InitGame: line 4\n")[:4],
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		r.SetMaxSize(test.maxSize)
+		lines := r.Stream(bytes.NewReader(test.input))
+
+		var readLines []string
+		for lines.Scan() {
+			readLines = append(readLines, lines.Text())
+		}
+		lines.Close()
+
+		if !reflect.DeepEqual(readLines, test.expectedLines) {
+			t.Errorf("%s: Expected %v, got %v", test.description, test.expectedLines, readLines)
+		}
+		if test.expectedError && lines.Err() == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+		if !test.expectedError && lines.Err() != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, lines.Err())
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	r := NewReader(zap.NewNop())
+
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name    string
+		modTime time.Time
+	}{
+		{name: "b.log.gz", modTime: start},
+		{name: "c.log", modTime: start.Add(-time.Hour)},
+		{name: "a.log.gz", modTime: start},
+		{name: "d.log.zst", modTime: start.Add(time.Hour)},
+	}
+	for _, file := range files {
+		filePath := filepath.Join(dir, file.name)
+		err := os.WriteFile(filePath, nil, 0644)
+		if err == nil {
+			err = os.Chtimes(filePath, file.modTime, file.modTime)
+		}
+		if err != nil {
+			t.Fatalf("Error writing test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		description   string
+		patterns      []string
+		expectedFiles []string
+		expectedError bool
+	}{
+		{
+			description:   "glob sorted by name whatever the modification time",
+			patterns:      []string{filepath.Join(dir, "*")},
+			expectedFiles: []string{"a.log.gz", "b.log.gz", "c.log", "d.log.zst"},
+		},
+		{
+			description:   "files in the order given",
+			patterns:      []string{filepath.Join(dir, "d.log.zst"), filepath.Join(dir, "c.log"), filepath.Join(dir, "a.log.gz")},
+			expectedFiles: []string{"d.log.zst", "c.log", "a.log.gz"},
+		},
+		{
+			description:   "overlapping patterns",
+			patterns:      []string{filepath.Join(dir, "d.log.zst"), filepath.Join(dir, "*.gz"), filepath.Join(dir, "a.*")},
+			expectedFiles: []string{"d.log.zst", "a.log.gz", "b.log.gz"},
+		},
+		{
+			description:   "missing file",
+			patterns:      []string{filepath.Join(dir, "missing.log")},
+			expectedError: true,
+		},
+		{
+			description:   "directory",
+			patterns:      []string{dir},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		filePaths, err := r.Expand(test.patterns...)
+		if test.expectedError {
+			if err == nil {
+				t.Errorf("%s: Expected error, got nil", test.description)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+
+		names := []string{}
+		for _, filePath := range filePaths {
+			names = append(names, filepath.Base(filePath))
+		}
+		if !reflect.DeepEqual(names, test.expectedFiles) {
+			t.Errorf("%s: Expected %v, got %v", test.description, test.expectedFiles, names)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gabriel-aranha/qk/internal/parser"
+	"github.com/gabriel-aranha/qk/internal/reader"
+	"github.com/gabriel-aranha/qk/internal/types"
+	"github.com/gabriel-aranha/qk/internal/writer"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxBodySize = 32 * 1024 * 1024
+	defaultMaxLogSize  = 256 * 1024 * 1024
+)
+
+// Server serves the games report over HTTP. A log uploaded to POST /report
+// replaces the report, which can then be fetched whole or by game, player and
+// rankings.
+type Server struct {
+	logger      *zap.Logger
+	maxBodySize int64
+	maxLogSize  int64
+	workers     int
+	lenient     bool
+
+	mu     sync.RWMutex
+	games  types.Games
+	loaded bool
+}
+
+type playerGame struct {
+	Game string `json:"game"`
+	types.Player
+}
+
+type playerReport struct {
+	Player  string         `json:"player"`
+	Ranking *types.Ranking `json:"ranking,omitempty"`
+	Games   []playerGame   `json:"games"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(logger *zap.Logger) *Server {
+	return &Server{
+		logger:      logger,
+		maxBodySize: defaultMaxBodySize,
+		maxLogSize:  defaultMaxLogSize,
+		workers:     1,
+	}
+}
+
+// SetMaxBodySize limits the size of uploaded logs, in bytes. Compressed logs
+// are limited by their compressed size.
+func (s *Server) SetMaxBodySize(maxBodySize int64) {
+	s.maxBodySize = maxBodySize
+}
+
+// SetMaxLogSize limits the size of uploaded logs once decompressed, in bytes,
+// so that a small compressed upload cannot exhaust the memory of the server.
+func (s *Server) SetMaxLogSize(maxLogSize int64) {
+	s.maxLogSize = maxLogSize
+}
+
+func (s *Server) SetWorkers(workers int) {
+	s.workers = workers
+}
+
+func (s *Server) SetLenient(lenient bool) {
+	s.lenient = lenient
+}
+
+// Load replaces the report, such as with the report of the logs given on the
+// command line.
+func (s *Server) Load(games types.Games) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.games = games
+	s.loaded = true
+}
+
+func (s *Server) report() (types.Games, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.games, s.loaded
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /report", s.handleUpload)
+	mux.HandleFunc("GET /report", s.handleReport)
+	mux.HandleFunc("GET /games/{key}", s.handleGame)
+	mux.HandleFunc("GET /players/{name}", s.handlePlayer)
+	mux.HandleFunc("GET /rankings", s.handleRankings)
+	return mux
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, s.maxBodySize)
+	reader := reader.NewReader(s.logger)
+	reader.SetMaxSize(s.maxLogSize)
+	lines := reader.Stream(body)
+	defer lines.Close()
+
+	// Parsing stops at the next game once the client goes away
+	ctx := r.Context()
+	parser := parser.NewParser(s.logger)
+	parser.SetLenient(s.lenient)
+	games := types.Games{Games: make(map[string]types.Game)}
+	err := parser.ParseConcurrent(lines, s.workers, func(key string, game types.Game) error {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+		games.Games[key] = game
+		return nil
+	})
+	if err != nil {
+		s.writeError(w, uploadStatus(err), err)
+		return
+	}
+
+	games.Rankings = parser.Rankings(games.Games)
+	s.Load(games)
+	s.writeJSON(w, http.StatusOK, games)
+}
+
+func uploadStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr), errors.Is(err, reader.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, bufio.ErrTooLong):
+		return http.StatusRequestEntityTooLarge
+	default:
+		// Malformed lines and logs that cannot be decompressed are the client's
+		return http.StatusBadRequest
+	}
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	games, ok := s.loadedReport(w)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, http.StatusOK, games)
+}
+
+func (s *Server) handleGame(w http.ResponseWriter, r *http.Request) {
+	games, ok := s.loadedReport(w)
+	if !ok {
+		return
+	}
+
+	key := r.PathValue("key")
+	game, ok := games.Games[key]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("game not found: %s", key))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, game)
+}
+
+// handlePlayer returns the stats of a player in every game they played, and
+// their ranking. Players are matched by name, as in the rankings.
+func (s *Server) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	games, ok := s.loadedReport(w)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	report := playerReport{Player: name, Games: []playerGame{}}
+	for _, key := range games.Games.Keys() {
+		for _, player := range games.Games[key].PlayerList {
+			if player.CurrentUsername == name {
+				report.Games = append(report.Games, playerGame{Game: key, Player: player})
+			}
+		}
+	}
+	for i := range games.Rankings {
+		if games.Rankings[i].Player == name {
+			report.Ranking = &games.Rankings[i]
+		}
+	}
+
+	if len(report.Games) == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("player not found: %s", name))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleRankings(w http.ResponseWriter, r *http.Request) {
+	games, ok := s.loadedReport(w)
+	if !ok {
+		return
+	}
+
+	rankings := games.Rankings
+	if rankings == nil {
+		rankings = []types.Ranking{}
+	}
+	s.writeJSON(w, http.StatusOK, rankings)
+}
+
+func (s *Server) loadedReport(w http.ResponseWriter) (types.Games, bool) {
+	games, ok := s.report()
+	if !ok {
+		s.writeError(w, http.StatusNotFound, errors.New("no report loaded, upload a log to POST /report"))
+	}
+	return games, ok
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		s.logger.Error("error handling request", zap.Error(err))
+	} else {
+		s.logger.Debug("rejected request", zap.Error(err))
+	}
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	writer := writer.NewWriter(s.logger)
+	err := writer.Encode(w, value)
+	if err != nil {
+		s.logger.Error("error writing response", zap.Error(err))
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+var testLog = strings.Join([]string{
+	"  0:00 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm17",
+	" 20:34 ClientConnect: 2",
+	" 20:34 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0\\model\\xian/default",
+	" 20:35 ClientConnect: 3",
+	" 20:35 ClientUserinfoChanged: 3 n\\Dono da Bola\\t\\0\\model\\sarge",
+	" 21:07 Kill: 2 3 10: Isgalamido killed Dono da Bola by MOD_RAILGUN",
+	" 21:15 ShutdownGame:",
+	" 21:20 InitGame: \\sv_hostname\\Code Miner Server\\g_gametype\\0\\mapname\\q3dm6",
+	" 21:21 ClientConnect: 2",
+	" 21:21 ClientUserinfoChanged: 2 n\\Isgalamido\\t\\0\\model\\xian/default",
+	" 21:40 Kill: 1022 2 22: <world> killed Isgalamido by MOD_TRIGGER_HURT",
+	" 21:42 ShutdownGame:",
+}, "\n")
+
+func gzipContent(t *testing.T, content string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		t.Fatalf("Error compressing test log: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func decodeResponse(t *testing.T, response *http.Response, value any) {
+	defer response.Body.Close()
+
+	err := json.NewDecoder(response.Body).Decode(value)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	s := NewServer(zap.NewNop())
+	s.SetMaxBodySize(int64(len(testLog)))
+	s.SetMaxLogSize(int64(len(testLog)))
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		description    string
+		body           []byte
+		expectedStatus int
+		expectedGames  []string
+	}{
+		{
+			description:    "plain log",
+			body:           []byte(testLog),
+			expectedStatus: http.StatusOK,
+			expectedGames:  []string{"game_1", "game_2"},
+		},
+		{
+			description:    "compressed log",
+			body:           gzipContent(t, testLog),
+			expectedStatus: http.StatusOK,
+			expectedGames:  []string{"game_1", "game_2"},
+		},
+		{
+			description:    "log over the size limit",
+			body:           []byte(testLog + "\n"),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			description:    "compressed log over the size limit once decompressed",
+			body:           gzipContent(t, testLog+strings.Repeat("\n", 1000)),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			description:    "malformed kill line",
+			body:           []byte("  0:00 InitGame: \\mapname\\q3dm17\n 0:10 Kill: 2 3 x: Isgalamido killed Dono da Bola by MOD_RAILGUN"),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		response, err := http.Post(server.URL+"/report", "text/plain", bytes.NewReader(test.body))
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+
+		if response.StatusCode != test.expectedStatus {
+			t.Errorf("%s: Expected status %d, got %d", test.description, test.expectedStatus, response.StatusCode)
+		}
+		if test.expectedStatus != http.StatusOK {
+			var errResponse errorResponse
+			decodeResponse(t, response, &errResponse)
+			if errResponse.Error == "" {
+				t.Errorf("%s: Expected an error message", test.description)
+			}
+			continue
+		}
+
+		var games types.Games
+		decodeResponse(t, response, &games)
+		if strings.Join(games.Games.Keys(), ",") != strings.Join(test.expectedGames, ",") {
+			t.Errorf("%s: Expected games %v, got %v", test.description, test.expectedGames, games.Games.Keys())
+		}
+		if len(games.Rankings) != 2 {
+			t.Errorf("%s: Expected 2 rankings, got %v", test.description, games.Rankings)
+		}
+	}
+}
+
+func TestUploadCanceled(t *testing.T) {
+	s := NewServer(zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodPost, "/report", strings.NewReader(testLog)).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if _, ok := s.report(); ok {
+		t.Errorf("Expected no report to be loaded")
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := NewServer(zap.NewNop())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	// Nothing can be fetched before a report is loaded
+	response, err := http.Get(server.URL + "/rankings")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d before loading, got %d", http.StatusNotFound, response.StatusCode)
+	}
+
+	response, err = http.Post(server.URL+"/report", "text/plain", strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	response.Body.Close()
+
+	tests := []struct {
+		description    string
+		path           string
+		expectedStatus int
+		expected       string
+	}{
+		{
+			description:    "report",
+			path:           "/report",
+			expectedStatus: http.StatusOK,
+			expected:       `"game_2": {`,
+		},
+		{
+			description:    "game",
+			path:           "/games/game_1",
+			expectedStatus: http.StatusOK,
+			expected:       `"map": "q3dm17"`,
+		},
+		{
+			description:    "missing game",
+			path:           "/games/game_9",
+			expectedStatus: http.StatusNotFound,
+			expected:       `"error": "game not found: game_9"`,
+		},
+		{
+			description:    "player",
+			path:           "/players/Dono%20da%20Bola",
+			expectedStatus: http.StatusOK,
+			expected:       `"game": "game_1"`,
+		},
+		{
+			description:    "missing player",
+			path:           "/players/Zeh",
+			expectedStatus: http.StatusNotFound,
+			expected:       `"error": "player not found: Zeh"`,
+		},
+		{
+			description:    "rankings",
+			path:           "/rankings",
+			expectedStatus: http.StatusOK,
+			expected:       `"player": "Isgalamido"`,
+		},
+	}
+
+	for _, test := range tests {
+		response, err := http.Get(server.URL + test.path)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Errorf("%s: Error reading response: %v", test.description, err)
+			continue
+		}
+
+		if response.StatusCode != test.expectedStatus {
+			t.Errorf("%s: Expected status %d, got %d", test.description, test.expectedStatus, response.StatusCode)
+		}
+		if response.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Expected a JSON response, got %s", test.description, response.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(string(body), test.expected) {
+			t.Errorf("%s: Expected %q in %s", test.description, test.expected, body)
+		}
+	}
+
+	// A player's stats come with their ranking
+	response, err = http.Get(server.URL + "/players/Isgalamido")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var report playerReport
+	decodeResponse(t, response, &report)
+	if len(report.Games) != 2 || report.Games[0].Game != "game_1" || report.Games[0].Kills != 1 {
+		t.Errorf("Expected the player in both games, got %+v", report.Games)
+	}
+	if report.Ranking == nil || report.Ranking.GamesPlayed != 2 {
+		t.Errorf("Expected the player ranking, got %+v", report.Ranking)
+	}
+}
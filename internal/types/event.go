@@ -0,0 +1,73 @@
+package types
+
+import "fmt"
+
+type EventKind string
+
+const (
+	EventInitGame              EventKind = "InitGame"
+	EventClientConnect         EventKind = "ClientConnect"
+	EventClientUserinfoChanged EventKind = "ClientUserinfoChanged"
+	EventClientBegin           EventKind = "ClientBegin"
+	EventClientDisconnect      EventKind = "ClientDisconnect"
+	EventKill                  EventKind = "Kill"
+	EventItem                  EventKind = "Item"
+	EventSay                   EventKind = "say"
+	EventScore                 EventKind = "score"
+	EventExit                  EventKind = "Exit"
+	EventShutdownGame          EventKind = "ShutdownGame"
+	EventTeamScore             EventKind = "TeamScore"
+	// EventDiagnostic stands for a malformed line skipped by the lenient parser
+	EventDiagnostic EventKind = "diagnostic"
+)
+
+// Timestamp is the server uptime printed at the start of every log line, in seconds.
+type Timestamp int
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d:%02d", int(t)/60, int(t)%60)
+}
+
+type Event struct {
+	Kind       EventKind         `json:"kind"`
+	Game       int               `json:"game"`
+	File       string            `json:"file,omitempty"`
+	Line       int               `json:"line"`
+	Time       Timestamp         `json:"time"`
+	ClientID   string            `json:"client_id,omitempty"`
+	Username   string            `json:"username,omitempty"`
+	Userinfo   *Userinfo         `json:"userinfo,omitempty"`
+	ServerVars map[string]string `json:"server_vars,omitempty"`
+	Kill       *Kill             `json:"kill,omitempty"`
+	Item       string            `json:"item,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Score      *Score            `json:"score,omitempty"`
+	TeamScore  *TeamScore        `json:"team_score,omitempty"`
+	Diagnostic *Diagnostic       `json:"diagnostic,omitempty"`
+}
+
+// GameKey returns the key of the event's game in Games, such as game_3.
+func (e Event) GameKey() string {
+	return FormatGameKey(e.Game)
+}
+
+type Kill struct {
+	KillerID string `json:"killer_id"`
+	VictimID string `json:"victim_id"`
+	MeansID  string `json:"means_id"`
+	Killer   string `json:"killer"`
+	Victim   string `json:"victim"`
+	Means    string `json:"means"`
+}
+
+type Score struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	Ping     int    `json:"ping"`
+}
+
+type TeamScore struct {
+	Red  int `json:"red"`
+	Blue int `json:"blue"`
+}
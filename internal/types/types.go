@@ -1,20 +1,199 @@
 package types
 
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type Game struct {
-	TotalKills   int            `json:"total_kills"`
-	Players      []string       `json:"players"`
-	Kills        map[string]int `json:"kills"`
-	KillsByMeans map[string]int `json:"kills_by_means"`
-	PlayerList   []Player       `json:"-"`
+	TotalKills     int                       `json:"total_kills"`
+	Players        []string                  `json:"players"`
+	Kills          map[string]int            `json:"kills"`
+	KillsByMeans   map[string]int            `json:"kills_by_means"`
+	KillMatrix     map[string]map[string]int `json:"kill_matrix"`
+	KillMatrixByID map[string]map[string]int `json:"kill_matrix_by_id"`
+	Metadata       *GameMetadata             `json:"metadata,omitempty"`
+	StartTime      Timestamp                 `json:"start_time"`
+	EndTime        Timestamp                 `json:"end_time"`
+	Duration       int                       `json:"duration"`
+	ExitReason     string                    `json:"exit_reason,omitempty"`
+	Incomplete     bool                      `json:"incomplete"`
+	Scoreboard     []Score                   `json:"scoreboard,omitempty"`
+	Mismatches     []Mismatch                `json:"score_mismatches,omitempty"`
+	Teams          *Teams                    `json:"teams,omitempty"`
+	PlayerList     []Player                  `json:"player_stats,omitempty"`
+	Diagnostics    []Diagnostic              `json:"diagnostics,omitempty"`
+}
+
+// Diagnostic describes a log line that was skipped by the lenient parser.
+type Diagnostic struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Game   string `json:"game"`
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+type Teams struct {
+	Red    Team   `json:"red"`
+	Blue   Team   `json:"blue"`
+	Winner string `json:"winner,omitempty"`
+}
+
+type Team struct {
+	Players []string `json:"players"`
+	Score   int      `json:"score"`
+	Kills   int      `json:"kills"`
+}
+
+type Mismatch struct {
+	Player        string `json:"player"`
+	UserID        string `json:"user_id"`
+	ComputedKills int    `json:"computed_kills"`
+	ComputedScore int    `json:"computed_score"`
+	ServerScore   int    `json:"server_score"`
+}
+
+type GameMetadata struct {
+	Map          string            `json:"map"`
+	GameType     string            `json:"game_type"`
+	GameTypeID   int               `json:"game_type_id"`
+	FragLimit    int               `json:"frag_limit"`
+	TimeLimit    int               `json:"time_limit"`
+	CaptureLimit int               `json:"capture_limit"`
+	Hostname     string            `json:"hostname"`
+	Version      string            `json:"version"`
+	Protocol     int               `json:"protocol"`
+	ServerVars   map[string]string `json:"server_vars"`
 }
 
 type Games struct {
-	Games map[string]Game `json:"games"`
+	Games    GameMap   `json:"games"`
+	Rankings []Ranking `json:"rankings,omitempty"`
+}
+
+// Ranking sums up the stats of a player across all games.
+type Ranking struct {
+	Player      string  `json:"player"`
+	GamesPlayed int     `json:"games_played"`
+	Wins        int     `json:"wins"`
+	Kills       int     `json:"kills"`
+	Frags       int     `json:"frags"`
+	Deaths      int     `json:"deaths"`
+	KDRatio     float64 `json:"kd_ratio"`
+}
+
+// GameMap holds the games by key. It is encoded to JSON in the order the games
+// were played, game_2 before game_10, instead of the lexical order of its keys.
+type GameMap map[string]Game
+
+// Keys returns the game keys in the order the games were played.
+func (m GameMap) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		iNumber, iOK := gameNumber(keys[i])
+		jNumber, jOK := gameNumber(keys[j])
+		if iOK && jOK && iNumber != jNumber {
+			return iNumber < jNumber
+		}
+		if iOK != jOK {
+			return iOK
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (m GameMap) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range m.Keys() {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		keyData, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		gameData, err := json.Marshal(m[key])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(keyData)
+		buffer.WriteByte(':')
+		buffer.Write(gameData)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// FormatGameKey returns the key of the game with the given number, such as game_3.
+func FormatGameKey(number int) string {
+	return "game_" + strconv.Itoa(number)
+}
+
+func gameNumber(key string) (int, bool) {
+	suffix, ok := strings.CutPrefix(key, "game_")
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.Atoi(suffix)
+	return number, err == nil
+}
+
+// Diagnostics returns the diagnostics of all games, in log order.
+func (g Games) Diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, key := range g.Games.Keys() {
+		diagnostics = append(diagnostics, g.Games[key].Diagnostics...)
+	}
+	return diagnostics
 }
 
 type Player struct {
-	CurrentUsername   string   `json:"current_username"`
-	UserID            string   `json:"user_id"`
-	PreviousUsernames []string `json:"previous_usernames"`
-	Kills             int      `json:"kills"`
+	CurrentUsername   string         `json:"current_username"`
+	UserID            string         `json:"user_id"`
+	PreviousUsernames []string       `json:"previous_usernames"`
+	Kills             int            `json:"kills"`
+	Frags             int            `json:"frags"`
+	Deaths            int            `json:"deaths"`
+	Suicides          int            `json:"suicides"`
+	WorldDeaths       int            `json:"world_deaths"`
+	Score             int            `json:"score"`
+	KDRatio           float64        `json:"kd_ratio"`
+	KillsByMeans      map[string]int `json:"kills_by_means"`
+	DeathsByMeans     map[string]int `json:"deaths_by_means"`
+	Team              string         `json:"team"`
+	Userinfo          Userinfo       `json:"userinfo"`
+	Disconnected      bool           `json:"-"`
+	// KillsByVictim counts the kills of each victim by their index in the
+	// PlayerList, until the kill matrix is built from the final names
+	KillsByVictim map[int]int `json:"-"`
+}
+
+type Userinfo struct {
+	Name       string            `json:"name"`
+	Team       string            `json:"team"`
+	Model      string            `json:"model"`
+	HeadModel  string            `json:"head_model"`
+	RedTeam    string            `json:"red_team"`
+	BlueTeam   string            `json:"blue_team"`
+	Color1     string            `json:"color1"`
+	Color2     string            `json:"color2"`
+	Handicap   int               `json:"handicap"`
+	Wins       int               `json:"wins"`
+	Losses     int               `json:"losses"`
+	TeamTask   int               `json:"team_task"`
+	TeamLeader bool              `json:"team_leader"`
+	Unknown    map[string]string `json:"unknown,omitempty"`
 }
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGameMapKeys(t *testing.T) {
+	tests := []struct {
+		description string
+		games       GameMap
+		expected    []string
+	}{
+		{
+			description: "numeric order",
+			games:       GameMap{"game_10": {}, "game_2": {}, "game_1": {}, "game_0": {}},
+			expected:    []string{"game_0", "game_1", "game_2", "game_10"},
+		},
+		{
+			description: "other keys last",
+			games:       GameMap{"final": {}, "game_3": {}, "game_x": {}},
+			expected:    []string{"game_3", "final", "game_x"},
+		},
+		{
+			description: "no games",
+			games:       GameMap{},
+			expected:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		keys := test.games.Keys()
+		if !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%s: Expected %v, got %v", test.description, test.expected, keys)
+		}
+	}
+}
+
+func TestGameMapMarshalJSON(t *testing.T) {
+	games := Games{Games: GameMap{
+		"game_10": {TotalKills: 10},
+		"game_2":  {TotalKills: 2},
+		"game_1":  {TotalKills: 1},
+	}}
+
+	data, err := json.Marshal(games)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded Games
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("Error decoding games: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, games) {
+		t.Errorf("Expected %+v, got %+v", games, decoded)
+	}
+
+	// Re-encoding the same games must give the same bytes, in play order
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("Expected stable output, got %s and %s", data, again)
+	}
+
+	var keys []string
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if key, ok := token.(string); ok && len(key) > 5 && key[:5] == "game_" {
+			keys = append(keys, key)
+		}
+	}
+	expected := []string{"game_1", "game_2", "game_10"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected games encoded as %v, got %v", expected, keys)
+	}
+}
@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+var gamesCSVHeader = []string{"game", "map", "game_type", "total_kills", "players", "duration", "exit_reason", "incomplete"}
+
+var playersCSVHeader = []string{"game", "player", "kills", "frags", "deaths", "suicides", "world_deaths", "score", "kd_ratio", "team"}
+
+// EncodeGamesCSV writes a CSV table with one row per game, in play order.
+func (w *Writer) EncodeGamesCSV(out io.Writer, games types.Games) error {
+	rows := [][]string{gamesCSVHeader}
+	for _, key := range games.Games.Keys() {
+		game := games.Games[key]
+
+		mapName, gameType := "", ""
+		if game.Metadata != nil {
+			mapName, gameType = game.Metadata.Map, game.Metadata.GameType
+		}
+		rows = append(rows, []string{
+			key,
+			mapName,
+			gameType,
+			strconv.Itoa(game.TotalKills),
+			strconv.Itoa(len(game.Players)),
+			strconv.Itoa(game.Duration),
+			game.ExitReason,
+			strconv.FormatBool(game.Incomplete),
+		})
+	}
+
+	return w.writeCSV(out, rows)
+}
+
+// EncodePlayersCSV writes a CSV table with one row per player of each game.
+// Kills and deaths by means get a kills_<means> and a deaths_<means> column
+// for every means seen in any game.
+func (w *Writer) EncodePlayersCSV(out io.Writer, games types.Games) error {
+	keys := games.Games.Keys()
+
+	meansSet := make(map[string]bool)
+	for _, key := range keys {
+		for _, player := range games.Games[key].PlayerList {
+			for means := range player.KillsByMeans {
+				meansSet[means] = true
+			}
+			for means := range player.DeathsByMeans {
+				meansSet[means] = true
+			}
+		}
+	}
+	means := make([]string, 0, len(meansSet))
+	for name := range meansSet {
+		means = append(means, name)
+	}
+	sort.Strings(means)
+
+	header := append([]string{}, playersCSVHeader...)
+	for _, name := range means {
+		header = append(header, "kills_"+name)
+	}
+	for _, name := range means {
+		header = append(header, "deaths_"+name)
+	}
+
+	rows := [][]string{header}
+	for _, key := range keys {
+		for _, player := range games.Games[key].PlayerList {
+			row := []string{
+				key,
+				player.CurrentUsername,
+				strconv.Itoa(player.Kills),
+				strconv.Itoa(player.Frags),
+				strconv.Itoa(player.Deaths),
+				strconv.Itoa(player.Suicides),
+				strconv.Itoa(player.WorldDeaths),
+				strconv.Itoa(player.Score),
+				strconv.FormatFloat(player.KDRatio, 'f', -1, 64),
+				player.Team,
+			}
+			for _, name := range means {
+				row = append(row, strconv.Itoa(player.KillsByMeans[name]))
+			}
+			for _, name := range means {
+				row = append(row, strconv.Itoa(player.DeathsByMeans[name]))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return w.writeCSV(out, rows)
+}
+
+func (w *Writer) writeCSV(out io.Writer, rows [][]string) error {
+	csvWriter := csv.NewWriter(out)
+	err := csvWriter.WriteAll(rows)
+	if err != nil {
+		w.logger.Error("error writing to output file", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package writer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+// eventRecord is an event as written to NDJSON, along with the key of its game.
+type eventRecord struct {
+	GameKey string `json:"game_key"`
+	types.Event
+}
+
+// EncodeEvent writes event as a single line of JSON, for log pipelines that
+// ingest newline-delimited JSON.
+func (w *Writer) EncodeEvent(out io.Writer, event types.Event) error {
+	return w.EncodeLine(out, eventRecord{GameKey: event.GameKey(), Event: event})
+}
+
+// EncodeLine writes value as compact JSON followed by a newline.
+func (w *Writer) EncodeLine(out io.Writer, value any) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		w.logger.Error("error marshalling output line", zap.Error(err))
+		return err
+	}
+
+	_, err = out.Write(append(jsonData, '\n'))
+	if err != nil {
+		w.logger.Error("error writing to output file", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+// Output writes a games report to one destination, in one format.
+type Output interface {
+	WriteGames(games types.Games) error
+}
+
+// Format encodes a games report to out. Formats are looked up by name, so a new
+// format only needs to be registered with Register to be usable.
+type Format func(w *Writer, out io.Writer, games types.Games) error
+
+var formats = map[string]Format{
+	"json": func(w *Writer, out io.Writer, games types.Games) error {
+		return w.Encode(out, games)
+	},
+	"csv-games": func(w *Writer, out io.Writer, games types.Games) error {
+		return w.EncodeGamesCSV(out, games)
+	},
+	"csv-players": func(w *Writer, out io.Writer, games types.Games) error {
+		return w.EncodePlayersCSV(out, games)
+	},
+	"markdown": func(w *Writer, out io.Writer, games types.Games) error {
+		return w.EncodeMarkdown(out, games)
+	},
+	"html": func(w *Writer, out io.Writer, games types.Games) error {
+		return w.EncodeHTML(out, games)
+	},
+}
+
+// Register makes a format available under name, replacing any format that was
+// registered under the same name. It is meant to be called from init functions.
+func Register(name string, format Format) {
+	formats[name] = format
+}
+
+// Formats returns the names of the registered formats, sorted.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (w *Writer) format(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s", name)
+	}
+	return format, nil
+}
+
+// NewFileOutput returns an Output that writes the named format to filePath,
+// creating its directory if needed.
+func (w *Writer) NewFileOutput(formatName, filePath string) (Output, error) {
+	format, err := w.format(formatName)
+	if err != nil {
+		return nil, err
+	}
+	return &fileOutput{writer: w, format: format, filePath: filePath}, nil
+}
+
+// NewStreamOutput returns an Output that writes the named format to out, such
+// as stdout.
+func (w *Writer) NewStreamOutput(formatName string, out io.Writer) (Output, error) {
+	format, err := w.format(formatName)
+	if err != nil {
+		return nil, err
+	}
+	return &streamOutput{writer: w, format: format, out: out}, nil
+}
+
+// Fanout returns an Output that writes the same report to all outputs. A failing
+// output does not stop the others, all errors are returned together.
+func Fanout(outputs ...Output) Output {
+	return fanout(outputs)
+}
+
+type fileOutput struct {
+	writer   *Writer
+	format   Format
+	filePath string
+}
+
+func (o *fileOutput) WriteGames(games types.Games) error {
+	file, err := o.writer.Create(o.filePath)
+	if err != nil {
+		return err
+	}
+
+	err = o.format(o.writer, file, games)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		o.writer.logger.Error("error closing output file", zap.Error(closeErr))
+		return closeErr
+	}
+	return nil
+}
+
+type streamOutput struct {
+	writer *Writer
+	format Format
+	out    io.Writer
+}
+
+func (o *streamOutput) WriteGames(games types.Games) error {
+	return o.format(o.writer, o.out, games)
+}
+
+type fanout []Output
+
+func (f fanout) WriteGames(games types.Games) error {
+	var errs []error
+	for _, output := range f {
+		err := output.WriteGames(games)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
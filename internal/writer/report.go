@@ -0,0 +1,173 @@
+package writer
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var defaultTemplates = map[string]string{
+	"markdown": "templates/report.md.tmpl",
+	"html":     "templates/report.html.tmpl",
+}
+
+// Report is the data given to the Markdown and HTML templates.
+type Report struct {
+	Games    []ReportGame
+	Rankings []types.Ranking
+}
+
+// ReportGame is a game of the report along with its key and the kills of each
+// weapon, sorted by kills.
+type ReportGame struct {
+	Key string
+	types.Game
+	Weapons []WeaponKills
+}
+
+type WeaponKills struct {
+	Means string
+	Kills int
+}
+
+// NewReport arranges games for the templates, in play order.
+func NewReport(games types.Games) Report {
+	report := Report{Rankings: games.Rankings}
+	for _, key := range games.Games.Keys() {
+		game := games.Games[key]
+		report.Games = append(report.Games, ReportGame{
+			Key:     key,
+			Game:    game,
+			Weapons: weaponKills(game.KillsByMeans),
+		})
+	}
+	return report
+}
+
+func weaponKills(killsByMeans map[string]int) []WeaponKills {
+	weapons := make([]WeaponKills, 0, len(killsByMeans))
+	for means, kills := range killsByMeans {
+		weapons = append(weapons, WeaponKills{Means: means, Kills: kills})
+	}
+	sort.Slice(weapons, func(i, j int) bool {
+		if weapons[i].Kills != weapons[j].Kills {
+			return weapons[i].Kills > weapons[j].Kills
+		}
+		return weapons[i].Means < weapons[j].Means
+	})
+	return weapons
+}
+
+var templateFuncs = map[string]any{
+	"inc": func(i int) int {
+		return i + 1
+	},
+	"duration": func(seconds int) string {
+		return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+	},
+	// cell escapes the characters that would break a Markdown table
+	"cell": func(s string) string {
+		s = strings.ReplaceAll(s, "|", "\\|")
+		return strings.ReplaceAll(s, "\n", " ")
+	},
+}
+
+// LoadTemplate replaces the template of the markdown or html format with the
+// template in filePath, so the layout of the report can be customised.
+func (w *Writer) LoadTemplate(format, filePath string) error {
+	if _, ok := defaultTemplates[format]; !ok {
+		return fmt.Errorf("format %s does not use templates", format)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		w.logger.Error("error reading template file", zap.Error(err))
+		return err
+	}
+
+	// The template is parsed now so that mistakes show up before any parsing
+	if format == "html" {
+		_, err = htmltemplate.New(filePath).Funcs(templateFuncs).Parse(string(content))
+	} else {
+		_, err = texttemplate.New(filePath).Funcs(templateFuncs).Parse(string(content))
+	}
+	if err != nil {
+		w.logger.Error("error parsing template file", zap.Error(err))
+		return err
+	}
+
+	if w.templates == nil {
+		w.templates = make(map[string]string)
+	}
+	w.templates[format] = string(content)
+	return nil
+}
+
+func (w *Writer) template(format string) (string, error) {
+	if text, ok := w.templates[format]; ok {
+		return text, nil
+	}
+
+	content, err := templateFiles.ReadFile(defaultTemplates[format])
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// EncodeMarkdown writes a Markdown report with a leaderboard and a table of
+// players and weapons for each game.
+func (w *Writer) EncodeMarkdown(out io.Writer, games types.Games) error {
+	text, err := w.template("markdown")
+	if err != nil {
+		w.logger.Error("error reading markdown template", zap.Error(err))
+		return err
+	}
+
+	tmpl, err := texttemplate.New("markdown").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		w.logger.Error("error parsing markdown template", zap.Error(err))
+		return err
+	}
+
+	err = tmpl.Execute(out, NewReport(games))
+	if err != nil {
+		w.logger.Error("error writing markdown report", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// EncodeHTML writes the same report as EncodeMarkdown as a single HTML page,
+// with its styles inlined.
+func (w *Writer) EncodeHTML(out io.Writer, games types.Games) error {
+	text, err := w.template("html")
+	if err != nil {
+		w.logger.Error("error reading html template", zap.Error(err))
+		return err
+	}
+
+	tmpl, err := htmltemplate.New("html").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		w.logger.Error("error parsing html template", zap.Error(err))
+		return err
+	}
+
+	err = tmpl.Execute(out, NewReport(games))
+	if err != nil {
+		w.logger.Error("error writing html report", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -10,7 +11,8 @@ import (
 )
 
 type Writer struct {
-	logger *zap.Logger
+	logger    *zap.Logger
+	templates map[string]string
 }
 
 func NewWriter(logger *zap.Logger) Writer {
@@ -20,6 +22,8 @@ func NewWriter(logger *zap.Logger) Writer {
 	return writer
 }
 
+// Write writes the JSON report to output/report.json under the working
+// directory.
 func (w *Writer) Write(games types.Games) error {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -29,32 +33,49 @@ func (w *Writer) Write(games types.Games) error {
 
 	filePath := filepath.Join(cwd, "output", "report.json")
 
+	return w.WriteFile(games, filePath)
+}
+
+// WriteFile writes the JSON report to filePath, as the json output of
+// NewFileOutput does.
+func (w *Writer) WriteFile(games types.Games, filePath string) error {
+	output, err := w.NewFileOutput("json", filePath)
+	if err != nil {
+		return err
+	}
+	return output.WriteGames(games)
+}
+
+func (w *Writer) Create(filePath string) (*os.File, error) {
 	dirPath := filepath.Dir(filePath)
 
-	_, err = os.Stat(dirPath)
+	_, err := os.Stat(dirPath)
 
 	if os.IsNotExist(err) {
 		err = os.MkdirAll(dirPath, 0755)
 		if err != nil {
 			w.logger.Error("error creating directory", zap.Error(err))
-			return err
+			return nil, err
 		}
 	}
 
 	file, err := os.Create(filePath)
 	if err != nil {
 		w.logger.Error("error creating output file", zap.Error(err))
-		return err
+		return nil, err
 	}
-	defer file.Close()
 
-	jsonData, err := json.MarshalIndent(games, "", "  ")
+	return file, nil
+}
+
+func (w *Writer) Encode(out io.Writer, value any) error {
+	jsonData, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		w.logger.Error("error marshalling output file", zap.Error(err))
 		return err
 	}
 
-	_, err = file.Write(jsonData)
+	_, err = out.Write(jsonData)
 	if err != nil {
 		w.logger.Error("error writing to output file", zap.Error(err))
 		return err
@@ -1,12 +1,18 @@
 package writer
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/gabriel-aranha/qk/internal/types"
+	"go.uber.org/zap"
 )
 
 func TestWrite(t *testing.T) {
@@ -62,3 +68,331 @@ func TestWrite(t *testing.T) {
 		file.Close()
 	}
 }
+
+func TestWriteFile(t *testing.T) {
+	w := NewWriter(nil)
+
+	games := types.Games{
+		Games: map[string]types.Game{
+			"game_1": {
+				TotalKills: 1,
+				Players:    []string{"player_1"},
+				Kills:      map[string]int{"player_1": 1},
+			},
+		},
+	}
+
+	tests := []struct {
+		description string
+		filePath    string
+	}{
+		{
+			description: "existing directory",
+			filePath:    filepath.Join(t.TempDir(), "report.json"),
+		},
+		{
+			description: "missing directory",
+			filePath:    filepath.Join(t.TempDir(), "reports", "daily", "report.json"),
+		},
+	}
+
+	for _, test := range tests {
+		err := w.WriteFile(games, test.filePath)
+		if err != nil {
+			t.Errorf("%s: Error writing to output file: %v", test.description, err)
+			continue
+		}
+
+		content, err := os.ReadFile(test.filePath)
+		if err != nil {
+			t.Errorf("%s: Error reading output file: %v", test.description, err)
+			continue
+		}
+
+		var decoded types.Games
+		err = json.Unmarshal(content, &decoded)
+		if err != nil {
+			t.Errorf("%s: Error decoding output file: %v", test.description, err)
+		}
+		if !reflect.DeepEqual(decoded, games) {
+			t.Errorf("%s: Expected %v, got %v", test.description, games, decoded)
+		}
+	}
+}
+
+var csvGames = types.Games{
+	Games: map[string]types.Game{
+		"game_10": {
+			TotalKills: 1,
+			Players:    []string{"Isgalamido", "Dono da Bola"},
+			Metadata:   &types.GameMetadata{Map: "q3dm17", GameType: "FFA"},
+			Duration:   95,
+			ExitReason: "Fraglimit hit",
+			PlayerList: []types.Player{
+				{
+					CurrentUsername: "Isgalamido",
+					Kills:           1,
+					Frags:           1,
+					Score:           1,
+					KDRatio:         1,
+					KillsByMeans:    map[string]int{"MOD_RAILGUN": 1},
+					DeathsByMeans:   map[string]int{},
+					Team:            "free",
+				},
+				{
+					CurrentUsername: "Dono da Bola",
+					Deaths:          1,
+					KillsByMeans:    map[string]int{},
+					DeathsByMeans:   map[string]int{"MOD_RAILGUN": 1},
+					Team:            "free",
+				},
+			},
+		},
+		"game_2": {
+			Players:    []string{"Mocinha, the \"Great\""},
+			Incomplete: true,
+			PlayerList: []types.Player{
+				{
+					CurrentUsername: "Mocinha, the \"Great\"",
+					Deaths:          2,
+					WorldDeaths:     2,
+					Score:           -2,
+					KillsByMeans:    map[string]int{},
+					DeathsByMeans:   map[string]int{"MOD_FALLING": 2},
+				},
+			},
+		},
+	},
+}
+
+func TestEncodeGamesCSV(t *testing.T) {
+	w := NewWriter(nil)
+
+	var out bytes.Buffer
+	err := w.EncodeGamesCSV(&out, csvGames)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"game,map,game_type,total_kills,players,duration,exit_reason,incomplete",
+		"game_2,,,0,1,0,,true",
+		"game_10,q3dm17,FFA,1,2,95,Fraglimit hit,false",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestEncodePlayersCSV(t *testing.T) {
+	w := NewWriter(nil)
+
+	var out bytes.Buffer
+	err := w.EncodePlayersCSV(&out, csvGames)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"game,player,kills,frags,deaths,suicides,world_deaths,score,kd_ratio,team,kills_MOD_FALLING,kills_MOD_RAILGUN,deaths_MOD_FALLING,deaths_MOD_RAILGUN",
+		"game_2,\"Mocinha, the \"\"Great\"\"\",0,0,2,0,2,-2,0,,0,0,2,0",
+		"game_10,Isgalamido,1,1,0,0,0,1,1,free,0,1,0,0",
+		"game_10,Dono da Bola,0,0,1,0,0,0,0,free,0,0,0,1",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestEncodeEvent(t *testing.T) {
+	w := NewWriter(nil)
+
+	events := []types.Event{
+		{Kind: types.EventInitGame, Game: 1, Line: 2, Time: 0, ServerVars: map[string]string{"mapname": "q3dm17"}},
+		{Kind: types.EventKill, Game: 12, Line: 40, Time: 1254, Kill: &types.Kill{KillerID: "1022", VictimID: "2", MeansID: "22", Killer: "<world>", Victim: "Isgalamido", Means: "MOD_TRIGGER_HURT"}},
+		{Kind: types.EventSay, Game: 12, Line: 41, Time: 1260, Username: "Isgalamido", Message: "gg"},
+	}
+
+	var out bytes.Buffer
+	for _, event := range events {
+		err := w.EncodeEvent(&out, event)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	expected := strings.Join([]string{
+		`{"game_key":"game_1","kind":"InitGame","game":1,"line":2,"time":0,"server_vars":{"mapname":"q3dm17"}}`,
+		`{"game_key":"game_12","kind":"Kill","game":12,"line":40,"time":1254,"kill":{"killer_id":"1022","victim_id":"2","means_id":"22","killer":"\u003cworld\u003e","victim":"Isgalamido","means":"MOD_TRIGGER_HURT"}}`,
+		`{"game_key":"game_12","kind":"say","game":12,"line":41,"time":1260,"username":"Isgalamido","message":"gg"}`,
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestEncodeReport(t *testing.T) {
+	w := NewWriter(nil)
+
+	games := csvGames
+	games.Rankings = []types.Ranking{
+		{Player: "Isgalamido", GamesPlayed: 1, Wins: 1, Kills: 1, Frags: 1, KDRatio: 1},
+		{Player: "Dono da Bola", GamesPlayed: 1, Deaths: 1},
+	}
+
+	tests := []struct {
+		description string
+		encode      func(out io.Writer, games types.Games) error
+		expected    []string
+	}{
+		{
+			description: "markdown",
+			encode:      w.EncodeMarkdown,
+			expected: []string{
+				"| 1 | Isgalamido | 1 | 1 | 1 | 0 | 1.00 |\n| 2 | Dono da Bola | 1 | 0 | 0 | 1 | 0.00 |\n",
+				"## game_2\n\n- **Total kills:** 0\n- **Duration:** 0:00\n- **Exit:** incomplete\n",
+				"| Mocinha, the \"Great\" | 0 | 2 | 0 | -2 | 0.00 |",
+				"## game_10\n\n- **Map:** q3dm17 (FFA)\n- **Total kills:** 1\n- **Duration:** 1:35\n- **Exit:** Fraglimit hit\n",
+				"| Isgalamido | 1 | 0 | 0 | 1 | 1.00 |\n| Dono da Bola | 0 | 1 | 0 | 0 | 0.00 |\n",
+			},
+		},
+		{
+			description: "html",
+			encode:      w.EncodeHTML,
+			expected: []string{
+				"<tr><td class=\"number\">1</td><td>Isgalamido</td>",
+				"<section id=\"game_2\">",
+				"<td>Mocinha, the &#34;Great&#34;</td>",
+				"<li><strong>Exit:</strong> Fraglimit hit</li>",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := test.encode(&out, games)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.description, err)
+			continue
+		}
+
+		for _, expected := range test.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("%s: Expected %q in %q", test.description, expected, out.String())
+			}
+		}
+
+		// Games are written in play order
+		if strings.Index(out.String(), "game_2") > strings.Index(out.String(), "game_10") {
+			t.Errorf("%s: Expected game_2 before game_10", test.description)
+		}
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	w := NewWriter(zap.NewNop())
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "games.tmpl")
+	err := os.WriteFile(filePath, []byte(`{{range .Games}}{{.Key}}: {{.TotalKills}} {{range .Weapons}}{{.Means}}{{end}};{{end}}`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing template file: %v", err)
+	}
+
+	err = w.LoadTemplate("markdown", filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = w.EncodeMarkdown(&out, csvGames)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "game_2: 0 ;game_10: 1 ;"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	brokenPath := filepath.Join(dir, "broken.tmpl")
+	err = os.WriteFile(brokenPath, []byte("{{range .Games}"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing template file: %v", err)
+	}
+
+	tests := []struct {
+		description string
+		format      string
+		filePath    string
+	}{
+		{"format without templates", "json", filePath},
+		{"missing file", "html", filepath.Join(dir, "missing.tmpl")},
+		{"broken template", "html", brokenPath},
+	}
+
+	for _, test := range tests {
+		err := w.LoadTemplate(test.format, test.filePath)
+		if err == nil {
+			t.Errorf("%s: Expected error, got nil", test.description)
+		}
+	}
+}
+
+type failingOutput struct{}
+
+func (o failingOutput) WriteGames(games types.Games) error {
+	return errors.New("disk full")
+}
+
+func TestOutputs(t *testing.T) {
+	w := NewWriter(nil)
+
+	Register("count", func(w *Writer, out io.Writer, games types.Games) error {
+		_, err := out.Write([]byte(strings.Repeat("#", len(games.Games))))
+		return err
+	})
+	defer delete(formats, "count")
+
+	games := types.Games{Games: map[string]types.Game{"game_1": {}, "game_2": {}}}
+	filePath := filepath.Join(t.TempDir(), "reports", "count.txt")
+
+	var stdout bytes.Buffer
+	streamOutput, err := w.NewStreamOutput("count", &stdout)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fileOutput, err := w.NewFileOutput("count", filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A failing output must not keep the others from being written
+	err = Fanout(streamOutput, failingOutput{}, fileOutput).WriteGames(games)
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the failing output error, got %v", err)
+	}
+
+	if stdout.String() != "##" {
+		t.Errorf("Expected %q on the stream, got %q", "##", stdout.String())
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Error reading output file: %v", err)
+	}
+	if string(content) != "##" {
+		t.Errorf("Expected %q in the file, got %q", "##", content)
+	}
+
+	_, err = w.NewFileOutput("xml", filePath)
+	if err == nil {
+		t.Errorf("Expected error for an unknown format, got nil")
+	}
+
+	expected := []string{"count", "csv-games", "csv-players", "html", "json", "markdown"}
+	if !reflect.DeepEqual(Formats(), expected) {
+		t.Errorf("Expected formats %v, got %v", expected, Formats())
+	}
+}
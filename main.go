@@ -1,34 +1,23 @@
 package main
 
 import (
-	"github.com/gabriel-aranha/qk/internal/parser"
-	"github.com/gabriel-aranha/qk/internal/reader"
-	"github.com/gabriel-aranha/qk/internal/writer"
-	"go.uber.org/zap"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gabriel-aranha/qk/internal/cli"
 )
 
 func main() {
-	logger, _ := zap.NewProduction()
-	defer logger.Sync()
-
-	reader := reader.NewReader(logger)
-	arrayLines, err := reader.Read("./input/games.log")
-	if err != nil {
-		logger.Error("error reading file", zap.Error(err))
-		return
-	}
-
-	parser := parser.NewParser(logger)
-	games, err := parser.Parse(arrayLines)
-	if err != nil {
-		logger.Error("error parsing file", zap.Error(err))
-		return
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	writer := writer.NewWriter(logger)
-	err = writer.Write(games)
+	cli := cli.NewCLI(os.Stdout, os.Stderr)
+	err := cli.Run(ctx, os.Args[1:])
 	if err != nil {
-		logger.Error("error writing file", zap.Error(err))
-		return
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }